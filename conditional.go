@@ -0,0 +1,107 @@
+package fuselage
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SetETag sets the ETag response header. The tag is quoted if needed and
+// prefixed with W/ when weak.
+func (c *Context) SetETag(tag string, weak bool) {
+	if !strings.HasPrefix(tag, `"`) {
+		tag = `"` + tag + `"`
+	}
+	if weak {
+		tag = "W/" + tag
+	}
+	c.SetHeader(HeaderETag, tag)
+}
+
+// SetLastModified sets the Last-Modified response header
+func (c *Context) SetLastModified(t time.Time) {
+	c.SetHeader(HeaderLastModified, t.UTC().Format(http.TimeFormat))
+}
+
+// CheckPreconditions evaluates the request's conditional headers against the
+// ETag and Last-Modified headers already set on the response, in the order
+// given by RFC 7232. It sends 304 Not Modified or 412 Precondition Failed and
+// returns true when the handler should stop:
+//
+//	c.SetETag(version, false)
+//	if c.CheckPreconditions() {
+//		return nil
+//	}
+func (c *Context) CheckPreconditions() bool {
+	switch evaluatePreconditions(c.Request, c.Response.Header()) {
+	case http.StatusNotModified:
+		header := c.Response.Header()
+		header.Del(HeaderContentType)
+		header.Del(HeaderContentLength)
+		c.SetStatus(http.StatusNotModified)
+		return true
+	case http.StatusPreconditionFailed:
+		_ = c.String(http.StatusPreconditionFailed, http.StatusText(http.StatusPreconditionFailed))
+		return true
+	}
+	return false
+}
+
+// evaluatePreconditions returns 304, 412 or 0 when the request may proceed
+func evaluatePreconditions(r *http.Request, header http.Header) int {
+	etag := header.Get(HeaderETag)
+	lastModified, _ := http.ParseTime(header.Get(HeaderLastModified))
+	safe := r.Method == GET || r.Method == HEAD
+
+	if ifMatch := r.Header.Get(HeaderIfMatch); ifMatch != "" {
+		if !matchETag(ifMatch, etag, lastModified, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get(HeaderIfUnmodifiedSince)); err == nil && !lastModified.IsZero() {
+		if lastModified.After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get(HeaderIfNoneMatch); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, lastModified, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+		return 0
+	}
+
+	if safe && !lastModified.IsZero() {
+		if since, err := http.ParseTime(r.Header.Get(HeaderIfModifiedSince)); err == nil && !lastModified.After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag reports whether a comma-separated If-Match or If-None-Match list
+// matches the current tag. "*" matches any existing representation. If-Match
+// uses strong comparison, If-None-Match weak comparison.
+func matchETag(list, etag string, lastModified time.Time, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return etag != "" || !lastModified.IsZero()
+		}
+		if etag == "" || candidate == "" {
+			continue
+		}
+		if strong {
+			if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package fuselage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContext_CheckPreconditions(t *testing.T) {
+	modified := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	router := New()
+	handler := func(c *Context) error {
+		c.SetETag("v2", false)
+		c.SetLastModified(modified)
+		if c.CheckPreconditions() {
+			return nil
+		}
+		return c.String(http.StatusOK, "document")
+	}
+	_ = router.GET("/doc", handler)
+	_ = router.PUT("/doc", handler)
+
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		value    string
+		expected int
+	}{
+		{"no conditions", "GET", "", "", http.StatusOK},
+		{"if-none-match hit", "GET", HeaderIfNoneMatch, `"v1", "v2"`, http.StatusNotModified},
+		{"if-none-match weak hit", "GET", HeaderIfNoneMatch, `W/"v2"`, http.StatusNotModified},
+		{"if-none-match miss", "GET", HeaderIfNoneMatch, `"v1"`, http.StatusOK},
+		{"if-modified-since not modified", "GET", HeaderIfModifiedSince, modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"if-modified-since modified", "GET", HeaderIfModifiedSince, modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{"if-match hit", "PUT", HeaderIfMatch, `"v2"`, http.StatusOK},
+		{"if-match stale", "PUT", HeaderIfMatch, `"v1"`, http.StatusPreconditionFailed},
+		{"if-match weak", "PUT", HeaderIfMatch, `W/"v2"`, http.StatusPreconditionFailed},
+		{"if-match any", "PUT", HeaderIfMatch, "*", http.StatusOK},
+		{"if-unmodified-since stale", "PUT", HeaderIfUnmodifiedSince, modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusPreconditionFailed},
+		{"if-none-match on put", "PUT", HeaderIfNoneMatch, "*", http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/doc", http.NoBody)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected empty body for 304")
+			}
+		})
+	}
+}
+
+func TestContext_SetETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest("GET", "/", http.NoBody), Response: NewResponseWriter(w)}
+
+	c.SetETag("abc", true)
+	if w.Header().Get(HeaderETag) != `W/"abc"` {
+		t.Errorf("Expected weak quoted tag, got '%s'", w.Header().Get(HeaderETag))
+	}
+
+	c.SetETag(`"xyz"`, false)
+	if w.Header().Get(HeaderETag) != `"xyz"` {
+		t.Errorf("Expected tag not to be quoted twice, got '%s'", w.Header().Get(HeaderETag))
+	}
+}
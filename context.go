@@ -10,10 +10,39 @@ import (
 // Context provides request/response handling
 type Context struct {
 	Request  *http.Request
-	Response http.ResponseWriter
+	Response *ResponseWriter
 	params   map[string]string
-	status   int
-	written  bool
+	route    string
+	router   *Router
+}
+
+// RoutePath returns the pattern of the matched route, e.g. "/users/:id",
+// or an empty string when no route matched
+func (c *Context) RoutePath() string {
+	return c.route
+}
+
+// RealIP returns the client IP, honoring forwarding headers only from
+// proxies trusted by the router's IPExtractor
+func (c *Context) RealIP() string {
+	return c.ipExtractor().ClientIP(c.Request)
+}
+
+// Scheme returns "https" or "http" as seen by the client
+func (c *Context) Scheme() string {
+	return c.ipExtractor().Scheme(c.Request)
+}
+
+// Host returns the host requested by the client
+func (c *Context) Host() string {
+	return c.ipExtractor().Host(c.Request)
+}
+
+func (c *Context) ipExtractor() *IPExtractor {
+	if c.router != nil && c.router.ipExtractor != nil {
+		return c.router.ipExtractor
+	}
+	return defaultIPExtractor
 }
 
 // Param gets URL parameter
@@ -59,8 +88,6 @@ func (c *Context) Bind(v interface{}) error {
 func (c *Context) JSON(status int, data interface{}) error {
 	c.Response.Header().Set("Content-Type", "application/json")
 	c.Response.WriteHeader(status)
-	c.status = status
-	c.written = true
 	return json.NewEncoder(c.Response).Encode(data)
 }
 
@@ -68,8 +95,6 @@ func (c *Context) JSON(status int, data interface{}) error {
 func (c *Context) String(status int, text string) error {
 	c.Response.Header().Set("Content-Type", "text/plain")
 	c.Response.WriteHeader(status)
-	c.status = status
-	c.written = true
 	_, err := c.Response.Write([]byte(text))
 	return err
 }
@@ -77,13 +102,11 @@ func (c *Context) String(status int, text string) error {
 // SetStatus sets response status
 func (c *Context) SetStatus(status int) {
 	c.Response.WriteHeader(status)
-	c.status = status
-	c.written = true
 }
 
 // Status gets response status
 func (c *Context) Status() int {
-	return c.status
+	return c.Response.Status()
 }
 
 // Header sets response header
@@ -98,7 +121,7 @@ func (c *Context) Header(key string) string {
 
 // IsWritten returns true if response has been sent
 func (c *Context) IsWritten() bool {
-	return c.written
+	return c.Response.Committed()
 }
 
 // Bind validates and binds JSON request body
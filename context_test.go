@@ -12,8 +12,7 @@ func TestContext_IsWritten(t *testing.T) {
 	w := httptest.NewRecorder()
 	c := &Context{
 		Request:  req,
-		Response: w,
-		written:  false,
+		Response: NewResponseWriter(w),
 	}
 
 	if c.IsWritten() {
@@ -32,8 +31,7 @@ func TestContext_IsWritten_String(t *testing.T) {
 	w := httptest.NewRecorder()
 	c := &Context{
 		Request:  req,
-		Response: w,
-		written:  false,
+		Response: NewResponseWriter(w),
 	}
 
 	// Test after String response
@@ -48,8 +46,7 @@ func TestContext_IsWritten_SetStatus(t *testing.T) {
 	w := httptest.NewRecorder()
 	c := &Context{
 		Request:  req,
-		Response: w,
-		written:  false,
+		Response: NewResponseWriter(w),
 	}
 
 	// Test after SetStatus
@@ -0,0 +1,248 @@
+package fuselage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCookieSize is the size most browsers accept for a single cookie
+const maxCookieSize = 4096
+
+var (
+	// ErrInvalidCookie is returned when a cookie fails verification or decryption
+	ErrInvalidCookie = errors.New("cookie: invalid value")
+	// ErrCookieExpired is returned when a cookie is older than the codec MaxAge
+	ErrCookieExpired = errors.New("cookie: expired")
+	// ErrCookieTooLong is returned when an encoded cookie exceeds 4096 bytes
+	ErrCookieTooLong = errors.New("cookie: encoded value too long")
+)
+
+// NewCookie creates a cookie with secure defaults: Path "/", HttpOnly, Secure and SameSite=Lax
+func NewCookie(name, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Cookie gets a request cookie
+func (c *Context) Cookie(name string) (*http.Cookie, error) {
+	return c.Request.Cookie(name)
+}
+
+// Cookies gets all request cookies
+func (c *Context) Cookies() []*http.Cookie {
+	return c.Request.Cookies()
+}
+
+// SetCookie adds a Set-Cookie header. An empty Path defaults to "/" and an
+// unset SameSite to Lax; use NewCookie for the full set of secure defaults.
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	// Copy so defaults never leak into a cookie the caller reuses
+	copied := *cookie
+	cookie = &copied
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 || cookie.SameSite == http.SameSiteDefaultMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(c.Response, cookie)
+}
+
+// DeleteCookie expires the cookie with the given name on the "/" path
+func (c *Context) DeleteCookie(name string) {
+	cookie := NewCookie(name, "")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	c.SetCookie(cookie)
+}
+
+// SetSecureCookie encodes the cookie value with codec before setting it
+func (c *Context) SetSecureCookie(codec CookieCodec, cookie *http.Cookie) error {
+	encoded, err := codec.Encode(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	copied := *cookie
+	copied.Value = encoded
+	c.SetCookie(&copied)
+	return nil
+}
+
+// SecureCookie gets a request cookie and decodes its value with codec
+func (c *Context) SecureCookie(codec CookieCodec, name string) (string, error) {
+	cookie, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return codec.Decode(name, cookie.Value)
+}
+
+// CookieCodec encodes and decodes cookie values.
+// The cookie name is bound to the value so it cannot be replayed under another name.
+type CookieCodec interface {
+	Encode(name, value string) (string, error)
+	Decode(name, encoded string) (string, error)
+}
+
+// SignedCookieCodec authenticates cookie values with HMAC-SHA256.
+// Values are readable by the client but cannot be modified.
+type SignedCookieCodec struct {
+	// MaxAge rejects values older than this duration (0 disables the check)
+	MaxAge time.Duration
+
+	keys [][]byte
+	now  func() time.Time
+}
+
+// NewSignedCookieCodec creates a signed codec. The first key signs new values;
+// all keys are accepted when verifying, which allows key rotation.
+func NewSignedCookieCodec(keys ...[]byte) (*SignedCookieCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("cookie: at least one key is required")
+	}
+	return &SignedCookieCodec{keys: keys, now: time.Now}, nil
+}
+
+// Encode signs value
+func (s *SignedCookieCodec) Encode(name, value string) (string, error) {
+	payload := timestampPayload(s.now(), value)
+	mac := signCookie(s.keys[0], name, payload)
+	encoded := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac)
+	if len(encoded) > maxCookieSize {
+		return "", ErrCookieTooLong
+	}
+	return encoded, nil
+}
+
+// Decode verifies encoded and returns the original value
+func (s *SignedCookieCodec) Decode(name, encoded string) (string, error) {
+	rawPayload, rawMAC, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(rawPayload)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(rawMAC)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range s.keys {
+		if hmac.Equal(mac, signCookie(key, name, payload)) {
+			return parseTimestampPayload(payload, s.MaxAge, s.now())
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// EncryptedCookieCodec encrypts and authenticates cookie values with AES-GCM
+type EncryptedCookieCodec struct {
+	// MaxAge rejects values older than this duration (0 disables the check)
+	MaxAge time.Duration
+
+	aeads []cipher.AEAD
+	now   func() time.Time
+}
+
+// NewEncryptedCookieCodec creates an encrypted codec from 16, 24 or 32 byte keys.
+// The first key encrypts new values; all keys are tried when decrypting.
+func NewEncryptedCookieCodec(keys ...[]byte) (*EncryptedCookieCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("cookie: at least one key is required")
+	}
+
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads = append(aeads, aead)
+	}
+	return &EncryptedCookieCodec{aeads: aeads, now: time.Now}, nil
+}
+
+// Encode encrypts value
+func (e *EncryptedCookieCodec) Encode(name, value string) (string, error) {
+	aead := e.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, timestampPayload(e.now(), value), []byte(name))
+	encoded := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(encoded) > maxCookieSize {
+		return "", ErrCookieTooLong
+	}
+	return encoded, nil
+}
+
+// Decode decrypts encoded and returns the original value
+func (e *EncryptedCookieCodec) Decode(name, encoded string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, aead := range e.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return parseTimestampPayload(payload, e.MaxAge, e.now())
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+func signCookie(key []byte, name string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'|'})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func timestampPayload(now time.Time, value string) []byte {
+	payload := strconv.AppendInt(nil, now.Unix(), 10)
+	payload = append(payload, '|')
+	return append(payload, value...)
+}
+
+func parseTimestampPayload(payload []byte, maxAge time.Duration, now time.Time) (string, error) {
+	rawTimestamp, value, ok := strings.Cut(string(payload), "|")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if maxAge > 0 && now.Sub(time.Unix(timestamp, 0)) > maxAge {
+		return "", ErrCookieExpired
+	}
+	return value, nil
+}
@@ -0,0 +1,175 @@
+package fuselage
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContext_Cookies(t *testing.T) {
+	router := New()
+	_ = router.GET("/cookie", func(c *Context) error {
+		cookie, err := c.Cookie("theme")
+		if err != nil {
+			return c.String(http.StatusBadRequest, "missing cookie")
+		}
+		c.SetCookie(NewCookie("seen", cookie.Value))
+		c.DeleteCookie("legacy")
+		return c.String(http.StatusOK, cookie.Value)
+	})
+
+	req := httptest.NewRequest("GET", "/cookie", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Body.String() != "dark" {
+		t.Errorf("Expected body 'dark', got '%s'", w.Body.String())
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("Expected 2 cookies, got %d", len(cookies))
+	}
+	seen := cookies[0]
+	if !seen.HttpOnly || !seen.Secure || seen.SameSite != http.SameSiteLaxMode || seen.Path != "/" {
+		t.Errorf("Expected secure defaults, got %+v", seen)
+	}
+	if cookies[1].Name != "legacy" || cookies[1].MaxAge >= 0 {
+		t.Errorf("Expected legacy cookie to be expired, got %+v", cookies[1])
+	}
+}
+
+func TestSignedCookieCodec(t *testing.T) {
+	oldKey := []byte("old-signing-key")
+	newKey := []byte("new-signing-key")
+
+	oldCodec, _ := NewSignedCookieCodec(oldKey)
+	encoded, err := oldCodec.Encode("session", "user=42")
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Rotated codec still accepts values signed with the old key
+	rotated, _ := NewSignedCookieCodec(newKey, oldKey)
+	value, err := rotated.Decode("session", encoded)
+	if err != nil || value != "user=42" {
+		t.Errorf("Expected 'user=42', got '%s' (%v)", value, err)
+	}
+
+	if _, err := rotated.Decode("other", encoded); err != ErrInvalidCookie {
+		t.Errorf("Expected ErrInvalidCookie for a different name, got %v", err)
+	}
+
+	tampered := strings.Replace(encoded, encoded[:2], "AA", 1)
+	if _, err := rotated.Decode("session", tampered); err != ErrInvalidCookie {
+		t.Errorf("Expected ErrInvalidCookie for tampered value, got %v", err)
+	}
+
+	newOnly, _ := NewSignedCookieCodec(newKey)
+	if _, err := newOnly.Decode("session", encoded); err != ErrInvalidCookie {
+		t.Errorf("Expected retired key to be rejected, got %v", err)
+	}
+}
+
+func TestSignedCookieCodec_MaxAge(t *testing.T) {
+	codec, _ := NewSignedCookieCodec([]byte("key"))
+	codec.MaxAge = time.Minute
+	now := time.Now()
+	codec.now = func() time.Time { return now }
+
+	encoded, _ := codec.Encode("flash", "hello")
+	now = now.Add(2 * time.Minute)
+
+	if _, err := codec.Decode("flash", encoded); err != ErrCookieExpired {
+		t.Errorf("Expected ErrCookieExpired, got %v", err)
+	}
+}
+
+func TestEncryptedCookieCodec(t *testing.T) {
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+
+	codec, err := NewEncryptedCookieCodec(key1)
+	if err != nil {
+		t.Fatalf("Failed to create codec: %v", err)
+	}
+	encoded, _ := codec.Encode("state", "secret")
+	if strings.Contains(encoded, "secret") {
+		t.Error("Expected value to be encrypted")
+	}
+
+	rotated, _ := NewEncryptedCookieCodec(key2, key1)
+	value, err := rotated.Decode("state", encoded)
+	if err != nil || value != "secret" {
+		t.Errorf("Expected 'secret', got '%s' (%v)", value, err)
+	}
+	if _, err := rotated.Decode("other", encoded); err != ErrInvalidCookie {
+		t.Errorf("Expected ErrInvalidCookie for a different name, got %v", err)
+	}
+
+	if _, err := NewEncryptedCookieCodec([]byte("short")); err == nil {
+		t.Error("Expected invalid key size to fail")
+	}
+}
+
+func TestContext_SecureCookie(t *testing.T) {
+	codec, _ := NewEncryptedCookieCodec(bytes.Repeat([]byte{7}, 16))
+
+	setReq := httptest.NewRequest("GET", "/", http.NoBody)
+	setRec := httptest.NewRecorder()
+	c := &Context{Request: setReq, Response: NewResponseWriter(setRec)}
+	if err := c.SetSecureCookie(codec, NewCookie("prefs", "lang=ja")); err != nil {
+		t.Fatalf("SetSecureCookie failed: %v", err)
+	}
+
+	getReq := httptest.NewRequest("GET", "/", http.NoBody)
+	for _, cookie := range setRec.Result().Cookies() {
+		getReq.AddCookie(cookie)
+	}
+	c = &Context{Request: getReq, Response: NewResponseWriter(httptest.NewRecorder())}
+
+	value, err := c.SecureCookie(codec, "prefs")
+	if err != nil || value != "lang=ja" {
+		t.Errorf("Expected 'lang=ja', got '%s' (%v)", value, err)
+	}
+}
+
+func TestContext_SetCookieDoesNotMutate(t *testing.T) {
+	codec, _ := NewSignedCookieCodec(bytes.Repeat([]byte{9}, 32))
+	template := &http.Cookie{Name: "prefs", Value: "lang=ja"}
+
+	rec := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest("GET", "/", http.NoBody), Response: NewResponseWriter(rec)}
+	for i := 0; i < 2; i++ {
+		if err := c.SetSecureCookie(codec, template); err != nil {
+			t.Fatalf("SetSecureCookie failed: %v", err)
+		}
+	}
+
+	if template.Value != "lang=ja" || template.Path != "" || template.SameSite != 0 {
+		t.Errorf("Expected template cookie to be unchanged, got %q %q %v", template.Value, template.Path, template.SameSite)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 || cookies[0].Value != cookies[1].Value {
+		t.Fatalf("Expected the same encoded value twice, got %v", cookies)
+	}
+	if value, err := codec.Decode("prefs", cookies[1].Value); err != nil || value != "lang=ja" {
+		t.Errorf("Expected reused template to encode once, got '%s' (%v)", value, err)
+	}
+}
+
+func TestContext_SetCookieDefaults(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := &Context{Request: httptest.NewRequest("GET", "/", http.NoBody), Response: NewResponseWriter(rec)}
+	c.SetCookie(&http.Cookie{Name: "theme", Value: "dark"})
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Path != "/" || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected Path=/ and SameSite=Lax defaults, got %v", cookies)
+	}
+}
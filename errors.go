@@ -0,0 +1,24 @@
+package fuselage
+
+import "net/http"
+
+// HTTPError is an error carrying the HTTP status to respond with
+type HTTPError struct {
+	Code    int
+	Message string
+}
+
+// NewHTTPError creates an HTTPError. An empty message defaults to the status text.
+func NewHTTPError(code int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+	return &HTTPError{Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// ErrRequestEntityTooLarge is returned when reading a request body beyond its limit
+var ErrRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge, "")
@@ -1,6 +1,7 @@
 package fuselage
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -89,6 +90,66 @@ func TestRouter_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+func TestRouter_Group(t *testing.T) {
+	router := New()
+	var order []string
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			order = append(order, "global")
+			return next(c)
+		}
+	})
+
+	api := router.Group("/api", func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			order = append(order, "group")
+			return next(c)
+		}
+	})
+	_ = api.GET("/users", func(c *Context) error {
+		return c.String(http.StatusOK, "users")
+	})
+	_ = router.GET("/health", func(c *Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/api/users", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "users" {
+		t.Fatalf("Expected group route to be served, got %d '%s'", w.Code, w.Body.String())
+	}
+	if strings.Join(order, ",") != "global,group" {
+		t.Errorf("Expected global then group middleware, got %v", order)
+	}
+
+	order = nil
+	req = httptest.NewRequest("GET", "/health", http.NoBody)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if strings.Join(order, ",") != "global" {
+		t.Errorf("Expected group middleware to stay within the group, got %v", order)
+	}
+}
+
+func TestRouter_HTTPError(t *testing.T) {
+	router := New()
+	_ = router.GET("/test", func(c *Context) error {
+		return fmt.Errorf("reading body: %w", ErrRequestEntityTooLarge)
+	})
+
+	req := httptest.NewRequest("GET", "/test", http.NoBody)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
 func TestContext_JSON(t *testing.T) {
 	router := New()
 	_ = router.GET("/json", func(c *Context) error {
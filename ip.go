@@ -0,0 +1,296 @@
+package fuselage
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPExtractor determines the client IP, scheme and host of a request.
+// Forwarding headers (Forwarded, X-Forwarded-*, X-Real-Ip) are only honored
+// when the connecting peer is within a trusted proxy range.
+type IPExtractor struct {
+	trusted []netip.Prefix
+}
+
+// defaultIPExtractor trusts no proxies
+var defaultIPExtractor = &IPExtractor{}
+
+// NewIPExtractor creates an extractor trusting proxies in the given CIDR
+// ranges; bare addresses are treated as single-host ranges. With no ranges,
+// the peer address is always the client.
+func NewIPExtractor(trustedProxies ...string) (*IPExtractor, error) {
+	e := &IPExtractor{}
+	for _, cidr := range trustedProxies {
+		prefix, err := ParseIPPrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		e.trusted = append(e.trusted, prefix)
+	}
+	return e, nil
+}
+
+// ParseIPPrefix parses a CIDR range, or a bare IP as a single-host range.
+// IPv4-mapped IPv6 input is converted to IPv4 so it matches unmapped client addresses.
+func ParseIPPrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// IsTrusted reports whether ip is within a trusted proxy range
+func (e *IPExtractor) IsTrusted(ip netip.Addr) bool {
+	ip = ip.Unmap().WithZone("")
+	for _, prefix := range e.trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client address. Behind trusted proxies, the Forwarded
+// or X-Forwarded-For chain is walked right to left and the first untrusted
+// hop is the client; X-Real-Ip is used when neither header is present.
+func (e *IPExtractor) ClientIP(r *http.Request) string {
+	ip, _ := e.resolve(r)
+	return ip
+}
+
+// Scheme returns "https" or "http". Behind a trusted proxy the Forwarded
+// proto, X-Forwarded-Proto, X-Forwarded-Protocol, X-Forwarded-Ssl and
+// X-Url-Scheme headers are consulted in that order; values other than http
+// and https are skipped.
+func (e *IPExtractor) Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if !e.peerTrusted(r) {
+		return "http"
+	}
+
+	if _, elem := e.resolve(r); elem != nil {
+		if scheme, ok := httpScheme(elem.proto); ok {
+			return scheme
+		}
+	}
+	if scheme, ok := httpScheme(lastListValue(r.Header.Values(HeaderXForwardedProto))); ok {
+		return scheme
+	}
+	if scheme, ok := httpScheme(lastListValue(r.Header.Values(HeaderXForwardedProtocol))); ok {
+		return scheme
+	}
+	if strings.EqualFold(r.Header.Get(HeaderXForwardedSsl), "on") {
+		return "https"
+	}
+	if scheme, ok := httpScheme(r.Header.Get(HeaderXUrlScheme)); ok {
+		return scheme
+	}
+	return "http"
+}
+
+// httpScheme normalizes a forwarded scheme, accepting only http and https
+func httpScheme(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s, s == "http" || s == "https"
+}
+
+// Host returns the host the client requested. Behind a trusted proxy the
+// Forwarded host or X-Forwarded-Host header replaces the Host header.
+func (e *IPExtractor) Host(r *http.Request) string {
+	if !e.peerTrusted(r) {
+		return r.Host
+	}
+	if _, elem := e.resolve(r); elem != nil && elem.host != "" {
+		return elem.host
+	}
+	if host := lastListValue(r.Header.Values(HeaderXForwardedHost)); host != "" {
+		return host
+	}
+	return r.Host
+}
+
+func (e *IPExtractor) peerTrusted(r *http.Request) bool {
+	peer, ok := parseHopAddr(r.RemoteAddr)
+	return ok && e.IsTrusted(peer)
+}
+
+// resolve walks the forwarding chain and returns the client IP together with
+// the Forwarded element describing it, if any
+func (e *IPExtractor) resolve(r *http.Request) (string, *forwardedElement) {
+	peer, ok := parseHopAddr(r.RemoteAddr)
+	if !ok {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr, nil
+		}
+		return host, nil
+	}
+	if !e.IsTrusted(peer) {
+		return peer.String(), nil
+	}
+
+	if values := r.Header.Values(HeaderForwarded); len(values) > 0 {
+		elements := parseForwarded(values)
+		hops := make([]string, len(elements))
+		for i, elem := range elements {
+			hops[i] = elem.forAddr
+		}
+		ip, i := e.walk(peer, hops)
+		if i < 0 {
+			return ip, nil
+		}
+		return ip, &elements[i]
+	}
+
+	if values := r.Header.Values(HeaderXForwardedFor); len(values) > 0 {
+		var hops []string
+		for _, v := range values {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+		ip, _ := e.walk(peer, hops)
+		return ip, nil
+	}
+
+	if realIP, ok := parseHopAddr(r.Header.Get(HeaderXRealIP)); ok {
+		return realIP.String(), nil
+	}
+	return peer.String(), nil
+}
+
+// walk scans hops right to left, returning the first untrusted address and its
+// index. An unparsable hop ends the walk at the proxy that reported it (index -1).
+func (e *IPExtractor) walk(peer netip.Addr, hops []string) (string, int) {
+	current, index := peer, -1
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHopAddr(hops[i])
+		if !ok {
+			return current.String(), index
+		}
+		current, index = addr, i
+		if !e.IsTrusted(addr) {
+			break
+		}
+	}
+	return current.String(), index
+}
+
+// parseHopAddr parses "ip", "ip:port", "[ipv6]" or "[ipv6]:port"
+func parseHopAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		if addr, err := netip.ParseAddr(s[1 : len(s)-1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// forwardedElement is one proxy hop of an RFC 7239 Forwarded header
+type forwardedElement struct {
+	forAddr string
+	proto   string
+	host    string
+}
+
+// parseForwarded splits Forwarded header values into elements
+func parseForwarded(values []string) []forwardedElement {
+	var elements []forwardedElement
+	for _, v := range values {
+		for _, part := range splitQuoted(v, ',') {
+			var elem forwardedElement
+			for _, pair := range splitQuoted(part, ';') {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				value = unquote(strings.TrimSpace(value))
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "for":
+					elem.forAddr = value
+				case "proto":
+					elem.proto = value
+				case "host":
+					elem.host = value
+				}
+			}
+			elements = append(elements, elem)
+		}
+	}
+	return elements
+}
+
+// splitQuoted splits s on sep outside double-quoted strings
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// lastListValue returns the last entry of a comma-separated header, which was
+// added by the nearest proxy
+func lastListValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	list := values[len(values)-1]
+	if i := strings.LastIndexByte(list, ','); i >= 0 {
+		list = list[i+1:]
+	}
+	return strings.TrimSpace(list)
+}
@@ -0,0 +1,182 @@
+package fuselage
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newProxyRequest(remoteAddr string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestIPExtractorClientIP(t *testing.T) {
+	extractor, err := NewIPExtractor("10.0.0.0/8", "192.168.1.1", "fd00::/8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"direct client", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted peer ignores headers", "203.0.113.5:1234",
+			map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-Ip": "1.2.3.4"}, "203.0.113.5"},
+		{"single trusted proxy", "10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"spoofed leftmost entry", "10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"bare trusted address", "192.168.1.1:80",
+			map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"all hops trusted", "10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"garbage hop stops walk", "10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "198.51.100.7, nonsense"}, "10.0.0.1"},
+		{"x-real-ip", "10.0.0.1:1234",
+			map[string]string{"X-Real-Ip": "198.51.100.7"}, "198.51.100.7"},
+		{"ipv6 peer", "[fd00::1]:443",
+			map[string]string{"X-Forwarded-For": "2001:db8::5"}, "2001:db8::5"},
+		{"forwarded header", "10.0.0.1:1234",
+			map[string]string{"Forwarded": `for=1.1.1.1, for="[2001:db8::7]:4711";proto=https, for=10.0.0.2`}, "2001:db8::7"},
+		{"forwarded wins over x-forwarded-for", "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=198.51.100.7", "X-Forwarded-For": "198.51.100.8"}, "198.51.100.7"},
+		{"obfuscated forwarded identifier", "10.0.0.1:1234",
+			map[string]string{"Forwarded": "for=_hidden"}, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractor.ClientIP(newProxyRequest(tt.remoteAddr, tt.headers))
+			if got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIPExtractorSchemeAndHost(t *testing.T) {
+	extractor, _ := NewIPExtractor("10.0.0.0/8")
+
+	req := newProxyRequest("10.0.0.1:1234", map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "example.com",
+	})
+	if extractor.Scheme(req) != "https" || extractor.Host(req) != "example.com" {
+		t.Errorf("Expected https://example.com, got %s://%s", extractor.Scheme(req), extractor.Host(req))
+	}
+
+	req = newProxyRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-Ssl": "on"})
+	if extractor.Scheme(req) != "https" {
+		t.Errorf("Expected X-Forwarded-Ssl to mean https")
+	}
+
+	req = newProxyRequest("10.0.0.1:1234", map[string]string{"X-Url-Scheme": "https"})
+	if extractor.Scheme(req) != "https" {
+		t.Errorf("Expected X-Url-Scheme to be honored")
+	}
+
+	req = newProxyRequest("10.0.0.1:1234", map[string]string{
+		"Forwarded":         `for=198.51.100.7;proto=https;host="shop.example.com"`,
+		"X-Forwarded-Proto": "http",
+	})
+	if extractor.Scheme(req) != "https" || extractor.Host(req) != "shop.example.com" {
+		t.Errorf("Expected Forwarded proto and host, got %s://%s", extractor.Scheme(req), extractor.Host(req))
+	}
+
+	req = newProxyRequest("203.0.113.5:1234", map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "evil.example",
+	})
+	if extractor.Scheme(req) != "http" || extractor.Host(req) != "example.com" {
+		t.Errorf("Expected untrusted headers to be ignored, got %s://%s", extractor.Scheme(req), extractor.Host(req))
+	}
+
+	req = newProxyRequest("10.0.0.1:1234", map[string]string{
+		"Forwarded":         "for=198.51.100.7;proto=javascript",
+		"X-Forwarded-Proto": "evil://",
+		"X-Url-Scheme":      "HTTPS",
+	})
+	if extractor.Scheme(req) != "https" {
+		t.Errorf("Expected bogus schemes to fall through to the next source, got %s", extractor.Scheme(req))
+	}
+
+	req = newProxyRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "ftp"})
+	if extractor.Scheme(req) != "http" {
+		t.Errorf("Expected unknown scheme to be ignored, got %s", extractor.Scheme(req))
+	}
+
+	req = newProxyRequest("203.0.113.5:1234", nil)
+	req.TLS = &tls.ConnectionState{}
+	if extractor.Scheme(req) != "https" {
+		t.Errorf("Expected TLS requests to be https")
+	}
+}
+
+func TestNewIPExtractorInvalidRange(t *testing.T) {
+	if _, err := NewIPExtractor("10.0.0.0/33"); err == nil {
+		t.Errorf("Expected error for invalid CIDR")
+	}
+	if _, err := NewIPExtractor("not-an-ip"); err == nil {
+		t.Errorf("Expected error for invalid address")
+	}
+}
+
+func TestParseIPPrefix(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"192.168.1.1", "192.168.1.1/32"},
+		{"::ffff:192.168.1.1", "192.168.1.1/32"},
+		{"::ffff:10.0.0.0/104", "10.0.0.0/8"},
+		{"::ffff:0:0/96", "0.0.0.0/0"},
+		// Mapped prefixes shorter than /96 cannot be expressed as IPv4
+		{"::ffff:0:0/64", "::/64"},
+	}
+
+	for _, tt := range tests {
+		prefix, err := ParseIPPrefix(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if prefix.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, prefix)
+		}
+	}
+}
+
+func TestContextRealIP(t *testing.T) {
+	router := New()
+	var ip, scheme string
+	_ = router.GET("/", func(c *Context) error {
+		ip, scheme = c.RealIP(), c.Scheme()
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := newProxyRequest("10.0.0.1:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7",
+		"X-Forwarded-Proto": "https",
+	})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if ip != "10.0.0.1" || scheme != "http" {
+		t.Errorf("Expected proxies to be untrusted by default, got %s %s", ip, scheme)
+	}
+
+	extractor, _ := NewIPExtractor("10.0.0.0/8")
+	router.Group("/api").SetIPExtractor(extractor)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if ip != "198.51.100.7" || scheme != "https" {
+		t.Errorf("Expected forwarded client, got %s %s", ip, scheme)
+	}
+}
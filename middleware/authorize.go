@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+// Policy decides whether a principal may access a request.
+// Authorize returns nil to allow, ErrForbidden (or an error wrapping it) to
+// deny, and any other error when the decision could not be made.
+type Policy interface {
+	Authorize(c *fuselage.Context, p *Principal) error
+}
+
+// PolicyFunc adapts a function to a Policy
+type PolicyFunc func(c *fuselage.Context, p *Principal) error
+
+// Authorize implements Policy
+func (f PolicyFunc) Authorize(c *fuselage.Context, p *Principal) error {
+	return f(c, p)
+}
+
+// RolesPolicy allows principals holding any of roles
+func RolesPolicy(roles ...string) Policy {
+	return PolicyFunc(func(c *fuselage.Context, p *Principal) error {
+		for _, role := range roles {
+			if containsString(p.Roles, role) {
+				return nil
+			}
+		}
+		return ErrForbidden
+	})
+}
+
+// ScopesPolicy allows principals granted all of scopes
+func ScopesPolicy(scopes ...string) Policy {
+	return PolicyFunc(func(c *fuselage.Context, p *Principal) error {
+		for _, scope := range scopes {
+			if !containsString(p.Scopes, scope) {
+				return ErrForbidden
+			}
+		}
+		return nil
+	})
+}
+
+// AllOf allows a request only if every policy allows it
+func AllOf(policies ...Policy) Policy {
+	return PolicyFunc(func(c *fuselage.Context, p *Principal) error {
+		for _, policy := range policies {
+			if err := policy.Authorize(c, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AnyOf allows a request if at least one policy allows it
+func AnyOf(policies ...Policy) Policy {
+	return PolicyFunc(func(c *fuselage.Context, p *Principal) error {
+		err := error(ErrForbidden)
+		for _, policy := range policies {
+			if err = policy.Authorize(c, p); err == nil {
+				return nil
+			}
+			if !errors.Is(err, ErrForbidden) {
+				return err
+			}
+		}
+		return err
+	})
+}
+
+type AuthorizeConfig struct {
+	// Policy decides access (required)
+	Policy Policy
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles ErrUnauthenticated and denied requests
+	ErrorHandler func(*fuselage.Context, error) error
+}
+
+var DefaultAuthorizeConfig = AuthorizeConfig{
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		status := http.StatusForbidden
+		if errors.Is(err, ErrUnauthenticated) {
+			status = http.StatusUnauthorized
+		}
+		return c.JSON(status, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+// Authorize enforces policy for the authenticated principal. Register it on a
+// route or group after an authentication middleware:
+//
+//	admin := router.Group("/admin", middleware.JWT(keys), middleware.RequireRoles("admin"))
+//	router.DELETE("/orders/:id", handler, middleware.RequireScopes("orders:write"))
+func Authorize(policy Policy) fuselage.MiddlewareFunc {
+	config := DefaultAuthorizeConfig
+	config.Policy = policy
+	return AuthorizeWithConfig(config)
+}
+
+// RequireRoles allows principals holding any of roles
+func RequireRoles(roles ...string) fuselage.MiddlewareFunc {
+	return Authorize(RolesPolicy(roles...))
+}
+
+// RequireScopes allows principals granted all of scopes
+func RequireScopes(scopes ...string) fuselage.MiddlewareFunc {
+	return Authorize(ScopesPolicy(scopes...))
+}
+
+// AuthorizeWithConfig responds 401 without a principal and 403 when the
+// policy denies access. Policy errors other than ErrForbidden are returned
+// to the router. It panics if Policy is nil.
+func AuthorizeWithConfig(config AuthorizeConfig) fuselage.MiddlewareFunc {
+	if config.Policy == nil {
+		panic("fuselage: authorize middleware requires a policy")
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultAuthorizeConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultAuthorizeConfig.ErrorHandler
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			p := GetPrincipal(c)
+			if p == nil {
+				return config.ErrorHandler(c, ErrUnauthenticated)
+			}
+
+			if err := config.Policy.Authorize(c, p); err != nil {
+				if errors.Is(err, ErrForbidden) {
+					return config.ErrorHandler(c, err)
+				}
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// HasRole reports whether the request's principal holds role
+func HasRole(c *fuselage.Context, role string) bool {
+	p := GetPrincipal(c)
+	return p != nil && containsString(p.Roles, role)
+}
+
+// HasScope reports whether the request's principal was granted scope
+func HasScope(c *fuselage.Context, scope string) bool {
+	p := GetPrincipal(c)
+	return p != nil && containsString(p.Scopes, scope)
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// withPrincipal authenticates every request as p, standing in for an auth middleware
+func withPrincipal(p *Principal) fuselage.MiddlewareFunc {
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if p != nil {
+				SetPrincipal(c, p)
+			}
+			return next(c)
+		}
+	}
+}
+
+func serveAuthorize(router *fuselage.Router, method, path string) int {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec.Code
+}
+
+func TestRequireRolesAndScopes(t *testing.T) {
+	router := fuselage.New()
+	router.Use(withPrincipal(&Principal{ID: "jo", Roles: []string{"support"}, Scopes: []string{"orders:read"}}))
+
+	ok := func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	}
+	admin := router.Group("/admin", RequireRoles("admin", "owner"))
+	admin.GET("/users", ok)
+	router.GET("/tickets", ok, RequireRoles("admin", "support"))
+	router.GET("/orders", ok, RequireScopes("orders:read"))
+	router.POST("/orders", ok, RequireScopes("orders:read", "orders:write"))
+
+	tests := []struct {
+		method, path string
+		expected     int
+	}{
+		{"GET", "/admin/users", http.StatusForbidden},
+		{"GET", "/tickets", http.StatusOK},
+		{"GET", "/orders", http.StatusOK},
+		{"POST", "/orders", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code := serveAuthorize(router, tt.method, tt.path); code != tt.expected {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.expected, code)
+		}
+	}
+}
+
+func TestAuthorizeUnauthenticated(t *testing.T) {
+	router := fuselage.New()
+	router.GET("/secret", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	}, RequireRoles("admin"))
+
+	if code := serveAuthorize(router, "GET", "/secret"); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without principal, got %d", code)
+	}
+}
+
+func TestAuthorizeCustomPolicy(t *testing.T) {
+	// Owners may edit their own documents; editors may edit any
+	ownsDocument := PolicyFunc(func(c *fuselage.Context, p *Principal) error {
+		if c.RoutePath() == "/docs/:owner" && c.Param("owner") == p.ID {
+			return nil
+		}
+		return ErrForbidden
+	})
+	failing := PolicyFunc(func(c *fuselage.Context, p *Principal) error {
+		return errors.New("policy engine unavailable")
+	})
+
+	router := fuselage.New()
+	router.Use(withPrincipal(&Principal{ID: "jo", Roles: []string{"viewer"}}))
+	router.PUT("/docs/:owner", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "saved")
+	}, Authorize(AnyOf(RolesPolicy("editor"), ownsDocument)))
+	router.GET("/broken", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	}, Authorize(AllOf(RolesPolicy("viewer"), failing)))
+
+	if code := serveAuthorize(router, "PUT", "/docs/jo"); code != http.StatusOK {
+		t.Errorf("Expected owner to be allowed, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("PUT", "/docs/sam", nil))
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "forbidden") {
+		t.Errorf("Expected 403 for other owners, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	if code := serveAuthorize(router, "GET", "/broken"); code != http.StatusInternalServerError {
+		t.Errorf("Expected policy errors to respond 500, got %d", code)
+	}
+}
+
+func TestHasRoleAndScope(t *testing.T) {
+	router := fuselage.New()
+	router.Use(withPrincipal(&Principal{Roles: []string{"admin"}, Scopes: []string{"read"}}))
+	router.GET("/", func(c *fuselage.Context) error {
+		if !HasRole(c, "admin") || HasRole(c, "owner") || !HasScope(c, "read") || HasScope(c, "write") {
+			return c.String(http.StatusInternalServerError, "unexpected")
+		}
+		return c.String(http.StatusOK, "OK")
+	})
+
+	if code := serveAuthorize(router, "GET", "/"); code != http.StatusOK {
+		t.Errorf("Expected helpers to reflect the principal, got %d", code)
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// quoteEscaper escapes a value for use in a quoted-string header parameter
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// BasicAuthValidator checks credentials. Returning an error responds with 500.
+type BasicAuthValidator func(user, password string, c *fuselage.Context) (bool, error)
+
+type BasicAuthConfig struct {
+	// Validator checks credentials (required)
+	Validator BasicAuthValidator
+	// Realm is sent in the WWW-Authenticate challenge
+	Realm string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles missing or invalid credentials after the challenge is set
+	ErrorHandler func(*fuselage.Context) error
+}
+
+var DefaultBasicAuthConfig = BasicAuthConfig{
+	Realm: "Restricted",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context) error {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Unauthorized",
+		})
+	},
+}
+
+// BasicAuth authenticates requests with HTTP Basic credentials
+func BasicAuth(validator BasicAuthValidator) fuselage.MiddlewareFunc {
+	config := DefaultBasicAuthConfig
+	config.Validator = validator
+	return BasicAuthWithConfig(config)
+}
+
+// BasicAuthWithConfig authenticates requests with HTTP Basic credentials and
+// stores a Principal with the user name unless the validator set one.
+// It panics if Validator is nil.
+func BasicAuthWithConfig(config BasicAuthConfig) fuselage.MiddlewareFunc {
+	if config.Validator == nil {
+		panic("fuselage: basic auth middleware requires a validator")
+	}
+	if config.Realm == "" {
+		config.Realm = DefaultBasicAuthConfig.Realm
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultBasicAuthConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultBasicAuthConfig.ErrorHandler
+	}
+
+	challenge := `Basic realm="` + quoteEscaper.Replace(config.Realm) + `", charset="UTF-8"`
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			user, password, ok := c.Request.BasicAuth()
+			if ok {
+				valid, err := config.Validator(user, password, c)
+				if err != nil {
+					return err
+				}
+				if valid {
+					if GetPrincipal(c) == nil {
+						SetPrincipal(c, &Principal{ID: user, Scheme: "basic"})
+					}
+					return next(c)
+				}
+			}
+
+			c.SetHeader(fuselage.HeaderWWWAuthenticate, challenge)
+			return config.ErrorHandler(c)
+		}
+	}
+}
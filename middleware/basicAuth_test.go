@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func TestBasicAuth(t *testing.T) {
+	router := fuselage.New()
+	router.Use(BasicAuth(func(user, password string, c *fuselage.Context) (bool, error) {
+		return SecureCompare(user, "admin") && SecureCompare(password, "s3cret"), nil
+	}))
+
+	router.GET("/admin", func(c *fuselage.Context) error {
+		p := GetPrincipal(c)
+		return c.String(http.StatusOK, p.Scheme+":"+p.ID)
+	})
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.SetBasicAuth("admin", "s3cret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "basic:admin" {
+		t.Errorf("Expected authenticated principal, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	for _, set := range []func(*http.Request){
+		func(r *http.Request) {},
+		func(r *http.Request) { r.SetBasicAuth("admin", "wrong") },
+		func(r *http.Request) { r.Header.Set("Authorization", "Basic !!!") },
+		func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") },
+	} {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		set(req)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", rec.Code)
+		}
+		if rec.Header().Get("WWW-Authenticate") != `Basic realm="Restricted", charset="UTF-8"` {
+			t.Errorf("Unexpected challenge '%s'", rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestBasicAuthWithConfig(t *testing.T) {
+	router := fuselage.New()
+	router.Use(BasicAuthWithConfig(BasicAuthConfig{
+		Realm: `Ops "internal"`,
+		Validator: func(user, password string, c *fuselage.Context) (bool, error) {
+			if user == "broken" {
+				return false, errors.New("directory unavailable")
+			}
+			SetPrincipal(c, &Principal{ID: user, Scheme: "basic", Roles: []string{"ops"}})
+			return password == "pw", nil
+		},
+	}))
+
+	router.GET("/ops", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetPrincipal(c).Roles[0])
+	})
+
+	req := httptest.NewRequest("GET", "/ops", nil)
+	req.SetBasicAuth("jo", "pw")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "ops" {
+		t.Errorf("Expected validator principal to be kept, got '%s'", rec.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/ops", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("WWW-Authenticate") != `Basic realm="Ops \"internal\"", charset="UTF-8"` {
+		t.Errorf("Expected escaped realm, got '%s'", rec.Header().Get("WWW-Authenticate"))
+	}
+
+	req = httptest.NewRequest("GET", "/ops", nil)
+	req.SetBasicAuth("broken", "pw")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for validator errors, got %d", rec.Code)
+	}
+}
+
+func TestSecureCompare(t *testing.T) {
+	if !SecureCompare("token", "token") {
+		t.Error("Expected equal strings to match")
+	}
+	if SecureCompare("token", "token2") || SecureCompare("", "x") {
+		t.Error("Expected different strings not to match")
+	}
+}
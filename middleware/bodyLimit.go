@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+type BodyLimitConfig struct {
+	// Limit is the maximum body size, e.g. "512KB", "4MB", "1GB" (units are powers of 1024)
+	Limit string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler responds when the declared Content-Length exceeds the limit
+	ErrorHandler func(*fuselage.Context) error
+}
+
+var DefaultBodyLimitConfig = BodyLimitConfig{
+	Limit: "4MB",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context) error {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fuselage.ErrRequestEntityTooLarge.Message,
+		})
+	},
+}
+
+// BodyLimit rejects request bodies larger than limit, e.g. BodyLimit("4MB")
+func BodyLimit(limit string) fuselage.MiddlewareFunc {
+	config := DefaultBodyLimitConfig
+	config.Limit = limit
+	return BodyLimitWithConfig(config)
+}
+
+// BodyLimitWithConfig rejects oversized bodies up front using Content-Length and
+// caps streamed bodies while they are read. Reads beyond the limit fail with
+// fuselage.ErrRequestEntityTooLarge, which the router answers with 413.
+// It panics if Limit is not a valid size.
+func BodyLimitWithConfig(config BodyLimitConfig) fuselage.MiddlewareFunc {
+	if config.Limit == "" {
+		config.Limit = DefaultBodyLimitConfig.Limit
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultBodyLimitConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultBodyLimitConfig.ErrorHandler
+	}
+
+	limit, err := ParseSize(config.Limit)
+	if err != nil {
+		panic("fuselage: " + err.Error())
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) || c.Request.Body == nil || c.Request.Body == http.NoBody {
+				return next(c)
+			}
+
+			if c.Request.ContentLength > limit {
+				return config.ErrorHandler(c)
+			}
+
+			c.Request.Body = &limitedBody{ReadCloser: c.Request.Body, remaining: limit}
+			return next(c)
+		}
+	}
+}
+
+// limitedBody fails reads once more than the allowed number of bytes has been consumed
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, fuselage.ErrRequestEntityTooLarge
+	}
+	// Read one byte past the limit so a body of exactly the limit is accepted
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), fuselage.ErrRequestEntityTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+var sizeUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+	"t":  1 << 40,
+	"tb": 1 << 40,
+}
+
+// ParseSize parses a human-readable size such as "100", "512KB", "1.5M" or "2GB".
+// Units are case-insensitive powers of 1024.
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	i := 0
+	for i < len(trimmed) && (trimmed[i] >= '0' && trimmed[i] <= '9' || trimmed[i] == '.') {
+		i++
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(trimmed[i:]))]
+	if i == 0 || !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseFloat(trimmed[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	size := n * float64(unit)
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(size), nil
+}
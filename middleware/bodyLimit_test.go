@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func TestBodyLimit(t *testing.T) {
+	router := fuselage.New()
+	router.Use(BodyLimit("1KB"))
+
+	router.POST("/upload", func(c *fuselage.Context) error {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, strconv.Itoa(len(body)))
+	})
+
+	// Exactly at the limit
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 1024)))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "1024" {
+		t.Errorf("Expected full body to be read, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	// Declared Content-Length over the limit
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 2048)))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Request Entity Too Large") {
+		t.Errorf("Expected error body, got '%s'", rec.Body.String())
+	}
+}
+
+func TestBodyLimitStreamed(t *testing.T) {
+	router := fuselage.New()
+	router.Use(BodyLimit("100B"))
+
+	router.POST("/json", func(c *fuselage.Context) error {
+		var v map[string]string
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, v)
+	})
+
+	// Unknown length, as with chunked transfer encoding
+	body := `{"data":"` + strings.Repeat("x", 200) + `"}`
+	req := httptest.NewRequest("POST", "/json", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rec.Code)
+	}
+}
+
+func TestBodyLimitPerGroup(t *testing.T) {
+	router := fuselage.New()
+	uploads := router.Group("/uploads", BodyLimit("10B"))
+
+	handler := func(c *fuselage.Context) error {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, "OK")
+	}
+	uploads.POST("/small", handler)
+	router.POST("/other", handler)
+
+	req := httptest.NewRequest("POST", "/uploads/small", strings.NewReader(strings.Repeat("a", 20)))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 inside group, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/other", strings.NewReader(strings.Repeat("a", 20)))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 outside group, got %d", rec.Code)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"100":   100,
+		"100B":  100,
+		"4MB":   4 << 20,
+		"512kb": 512 << 10,
+		"1.5G":  3 << 29,
+		" 2 TB": 2 << 40,
+	}
+	for input, expected := range tests {
+		size, err := ParseSize(input)
+		if err != nil {
+			t.Errorf("ParseSize(%q) returned error: %v", input, err)
+			continue
+		}
+		if size != expected {
+			t.Errorf("ParseSize(%q) = %d, expected %d", input, size, expected)
+		}
+	}
+
+	for _, input := range []string{"", "MB", "4XB", "1.2.3KB"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+type CacheConfig struct {
+	// Store holds cached responses. Share a store to invalidate entries from
+	// handlers, and register it with Router.AddCloser to wait for background
+	// revalidation on shutdown.
+	Store *CacheStore
+	// TTL is the lifetime of responses without max-age or s-maxage
+	TTL time.Duration
+	// StaleWhileRevalidate is how long an expired response may still be served
+	// while it is refreshed in the background, unless the response sets its own
+	StaleWhileRevalidate time.Duration
+	// VaryHeaders lists request headers that are part of the cache key.
+	// Responses varying on other headers are not cached.
+	VaryHeaders []string
+	// CredentialHeaders lists request headers that carry credentials. Requests
+	// sending any of them only share responses marked public or s-maxage
+	// (RFC 9111 section 3.5), since a hit skips authentication middleware.
+	CredentialHeaders []string
+	// Header is the response header reporting HIT, STALE or MISS
+	Header string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+}
+
+var DefaultCacheConfig = CacheConfig{
+	TTL:               time.Minute,
+	VaryHeaders:       []string{fuselage.HeaderAcceptEncoding},
+	CredentialHeaders: []string{fuselage.HeaderAuthorization, fuselage.HeaderCookie},
+	Header:            "X-Cache",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+}
+
+func Cache() fuselage.MiddlewareFunc {
+	return CacheWithConfig(DefaultCacheConfig)
+}
+
+// CacheWithConfig stores complete GET responses and serves them until they
+// expire. Handlers control caching with Cache-Control: no-store, no-cache and
+// private responses are never stored, s-maxage takes precedence over max-age,
+// and stale-while-revalidate allows serving expired entries while refreshing.
+// Responses setting cookies or Vary: * are never stored. Requests with
+// credentials (Authorization or Cookie by default) bypass the cache unless
+// the response is public or sets s-maxage.
+func CacheWithConfig(config CacheConfig) fuselage.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = NewCacheStore("64MB")
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheConfig.TTL
+	}
+	if config.Header == "" {
+		config.Header = DefaultCacheConfig.Header
+	}
+	if config.VaryHeaders == nil {
+		config.VaryHeaders = DefaultCacheConfig.VaryHeaders
+	}
+	if config.CredentialHeaders == nil {
+		config.CredentialHeaders = DefaultCacheConfig.CredentialHeaders
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultCacheConfig.Skipper
+	}
+
+	store := config.Store
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			// Upgrades and event streams must reach the real writer
+			if config.Skipper(c) || !bufferable(c) {
+				return next(c)
+			}
+
+			key := cacheKey(c.Request, config.VaryHeaders)
+			credentialed := hasAnyHeader(c.Request, config.CredentialHeaders)
+			entry, fresh := store.lookup(key)
+			if entry != nil && (entry.shared || !credentialed) {
+				state := "HIT"
+				if !fresh {
+					state = "STALE"
+					if store.startRevalidation(entry) {
+						go revalidate(detachContext(c), next, &config, key, entry)
+					}
+				}
+				return serveCached(c, entry, store.now(), config.Header, state)
+			}
+
+			if c.Request.Method == fuselage.HEAD {
+				return next(c)
+			}
+
+			buf, err := bufferResponse(c, next, http.Header{})
+			if buf.status == 0 {
+				return err
+			}
+
+			if entry := newCacheEntry(key, buf, &config, store.now(), credentialed); err == nil && entry != nil {
+				store.add(entry)
+			}
+
+			header := c.Response.Header()
+			for name, values := range buf.header {
+				header[name] = values
+			}
+			header.Set(config.Header, "MISS")
+			if writeErr := buf.writeTo(c.Response); err == nil {
+				err = writeErr
+			}
+			return err
+		}
+	}
+}
+
+// detachContext copies the context for use after the request completes
+func detachContext(c *fuselage.Context) *fuselage.Context {
+	cc := *c
+	cc.Request = c.Request.Clone(context.WithoutCancel(c.Request.Context()))
+	cc.Request.Method = fuselage.GET
+	cc.Response = fuselage.NewResponseWriter(&responseBuffer{header: http.Header{}})
+	return &cc
+}
+
+// revalidate refreshes a stale entry by running the handler on a detached context
+func revalidate(c *fuselage.Context, next fuselage.HandlerFunc, config *CacheConfig, key string, stale *cacheEntry) {
+	defer config.Store.finishRevalidation(stale)
+	defer func() {
+		_ = recover()
+	}()
+
+	buf, err := bufferResponse(c, next, http.Header{})
+	if err != nil {
+		return
+	}
+	credentialed := hasAnyHeader(c.Request, config.CredentialHeaders)
+	if entry := newCacheEntry(key, buf, config, config.Store.now(), credentialed); entry != nil {
+		config.Store.add(entry)
+	}
+}
+
+func serveCached(c *fuselage.Context, entry *cacheEntry, now time.Time, cacheHeader, state string) error {
+	header := c.Response.Header()
+	for name, values := range entry.header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(fuselage.HeaderAge, strconv.Itoa(int(now.Sub(entry.storedAt)/time.Second)))
+	header.Set(fuselage.HeaderContentLength, strconv.Itoa(len(entry.body)))
+	header.Set(cacheHeader, state)
+	c.SetStatus(entry.status)
+	if c.Request.Method == fuselage.HEAD {
+		return nil
+	}
+	_, err := c.Response.Write(entry.body)
+	return err
+}
+
+// cacheKey starts with the request path so entries can be invalidated by path prefix
+func cacheKey(r *http.Request, varyHeaders []string) string {
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+	if query := r.URL.Query(); len(query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(query.Encode())
+	}
+	for _, name := range varyHeaders {
+		b.WriteByte(0)
+		b.WriteString(strings.ToLower(name))
+		b.WriteByte('=')
+		b.WriteString(r.Header.Get(name))
+	}
+	return b.String()
+}
+
+type cacheEntry struct {
+	key        string
+	status     int
+	header     http.Header
+	body       []byte
+	storedAt   time.Time
+	expires    time.Time
+	staleUntil time.Time
+	// shared entries (public or s-maxage) may also be served to requests with credentials
+	shared bool
+
+	revalidating bool
+}
+
+func (e *cacheEntry) size() int64 {
+	size := int64(len(e.key) + len(e.body))
+	for name, values := range e.header {
+		size += int64(len(name))
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+// newCacheEntry returns nil when the response must not be stored
+func newCacheEntry(key string, buf *responseBuffer, config *CacheConfig, now time.Time, credentialed bool) *cacheEntry {
+	if buf.status != http.StatusOK || buf.header.Get(fuselage.HeaderSetCookie) != "" {
+		return nil
+	}
+	if !varyCovered(buf.header.Values(fuselage.HeaderVary), config.VaryHeaders) {
+		return nil
+	}
+
+	directives := parseCacheControl(buf.header.Get(fuselage.HeaderCacheControl))
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return nil
+	}
+	if _, ok := directives["private"]; ok {
+		return nil
+	}
+
+	_, public := directives["public"]
+	_, sharedMaxAge := directives["s-maxage"]
+	shared := public || sharedMaxAge
+	if credentialed && !shared {
+		return nil
+	}
+
+	ttl := config.TTL
+	if v, ok := directives["s-maxage"]; ok {
+		ttl = parseSeconds(v)
+	} else if v, ok := directives["max-age"]; ok {
+		ttl = parseSeconds(v)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	stale := config.StaleWhileRevalidate
+	if v, ok := directives["stale-while-revalidate"]; ok {
+		stale = parseSeconds(v)
+	}
+
+	header := buf.header.Clone()
+	header.Del(fuselage.HeaderContentLength)
+	return &cacheEntry{
+		key:        key,
+		status:     buf.status,
+		header:     header,
+		body:       append([]byte(nil), buf.body.Bytes()...),
+		storedAt:   now,
+		expires:    now.Add(ttl),
+		staleUntil: now.Add(ttl + stale),
+		shared:     shared,
+	}
+}
+
+// hasAnyHeader reports whether the request sends any of the named headers
+func hasAnyHeader(r *http.Request, names []string) bool {
+	for _, name := range names {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// varyCovered reports whether every header the response varies on is part of the key
+func varyCovered(vary []string, keyed []string) bool {
+	for _, v := range vary {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return false
+			}
+			found := false
+			for _, k := range keyed {
+				if strings.EqualFold(k, name) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+	}
+	return directives
+}
+
+func parseSeconds(value string) time.Duration {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+// CacheStore is an in-memory LRU of cached responses bounded by total size
+type CacheStore struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	items    map[string]*list.Element
+	lru      *list.List
+	now      func() time.Time
+
+	// revalidations tracks background refreshes so Close can wait for them
+	revalidations sync.WaitGroup
+	closed        bool
+}
+
+// NewCacheStore creates a store holding at most maxSize of responses, e.g. "64MB".
+// It panics if maxSize is not a valid size.
+func NewCacheStore(maxSize string) *CacheStore {
+	maxBytes, err := ParseSize(maxSize)
+	if err != nil {
+		panic("fuselage: " + err.Error())
+	}
+	return &CacheStore{
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// InvalidatePrefix removes all entries whose request path starts with prefix
+// and returns the number removed
+func (s *CacheStore) InvalidatePrefix(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, elem := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Purge removes all entries
+func (s *CacheStore) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = make(map[string]*list.Element)
+	s.lru.Init()
+	s.bytes = 0
+}
+
+// Len returns the number of cached responses
+func (s *CacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// lookup returns the entry for key and whether it is still fresh.
+// Entries past their stale window are removed.
+func (s *CacheStore) lookup(key string) (*cacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	now := s.now()
+	if !now.Before(entry.staleUntil) {
+		s.remove(elem)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry, now.Before(entry.expires)
+}
+
+func (s *CacheStore) add(entry *cacheEntry) {
+	size := entry.size()
+	if size > s.maxBytes {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[entry.key]; ok {
+		s.remove(elem)
+	}
+	s.items[entry.key] = s.lru.PushFront(entry)
+	s.bytes += size
+
+	for s.bytes > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *CacheStore) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	s.lru.Remove(elem)
+	delete(s.items, entry.key)
+	s.bytes -= entry.size()
+}
+
+// Close stops background revalidation and waits for running refreshes to
+// finish. Stale entries are still served afterwards. Register the store with
+// Router.AddCloser to close it on shutdown.
+func (s *CacheStore) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.revalidations.Wait()
+	return nil
+}
+
+// startRevalidation marks a stale entry as being refreshed, reporting false
+// if a refresh is already running or the store is closed
+func (s *CacheStore) startRevalidation(entry *cacheEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.revalidating || s.closed {
+		return false
+	}
+	entry.revalidating = true
+	s.revalidations.Add(1)
+	return true
+}
+
+func (s *CacheStore) finishRevalidation(entry *cacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.revalidating = false
+	s.revalidations.Done()
+}
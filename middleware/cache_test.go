@@ -0,0 +1,412 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func serveCache(router *fuselage.Router, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	router := fuselage.New()
+	router.Use(Cache())
+
+	router.GET("/report", func(c *fuselage.Context) error {
+		calls++
+		return c.String(http.StatusOK, "report "+strconv.Itoa(calls))
+	})
+
+	rec := serveCache(router, "/report")
+	if rec.Header().Get("X-Cache") != "MISS" || rec.Body.String() != "report 1" {
+		t.Fatalf("Expected miss, got '%s' '%s'", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	rec = serveCache(router, "/report")
+	if rec.Header().Get("X-Cache") != "HIT" || rec.Body.String() != "report 1" {
+		t.Errorf("Expected hit, got '%s' '%s'", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected cached headers to be replayed")
+	}
+
+	rec = serveCache(router, "/report?page=2")
+	if rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected query to be part of the key")
+	}
+
+	rec = serveCache(router, "/report", "Accept-Encoding", "gzip")
+	if rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected vary header to be part of the key")
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 handler calls, got %d", calls)
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	store := NewCacheStore("1MB")
+	router := fuselage.New()
+	router.Use(CacheWithConfig(CacheConfig{Store: store, TTL: time.Minute}))
+
+	for _, cc := range []string{"no-store", "private, max-age=60", "no-cache", "max-age=0"} {
+		value := cc
+		router.GET("/"+strings.NewReplacer(" ", "", ",", "-", "=", "-").Replace(value), func(c *fuselage.Context) error {
+			c.SetHeader("Cache-Control", value)
+			return c.String(http.StatusOK, "OK")
+		})
+	}
+	router.GET("/cookie", func(c *fuselage.Context) error {
+		c.SetCookie(&http.Cookie{Name: "a", Value: "b"})
+		return c.String(http.StatusOK, "OK")
+	})
+	router.GET("/error", func(c *fuselage.Context) error {
+		return c.String(http.StatusInternalServerError, "boom")
+	})
+	router.GET("/vary", func(c *fuselage.Context) error {
+		c.SetHeader("Vary", "Accept-Language")
+		return c.String(http.StatusOK, "OK")
+	})
+
+	for _, path := range []string{"/no-store", "/private-max-age-60", "/no-cache", "/max-age-0", "/cookie", "/error", "/vary"} {
+		serveCache(router, path)
+		if rec := serveCache(router, path); rec.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("Expected %s not to be cached", path)
+		}
+	}
+	if store.Len() != 0 {
+		t.Errorf("Expected empty store, got %d entries", store.Len())
+	}
+}
+
+func TestCacheSharedMaxAge(t *testing.T) {
+	store := NewCacheStore("1MB")
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return clock }
+
+	router := fuselage.New()
+	router.Use(CacheWithConfig(CacheConfig{Store: store}))
+	router.GET("/test", func(c *fuselage.Context) error {
+		c.SetHeader("Cache-Control", "public, max-age=10, s-maxage=120")
+		return c.String(http.StatusOK, "OK")
+	})
+
+	serveCache(router, "/test")
+	clock = clock.Add(time.Minute)
+
+	rec := serveCache(router, "/test")
+	if rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected s-maxage to take precedence over max-age")
+	}
+	if rec.Header().Get("Age") != "60" {
+		t.Errorf("Expected Age 60, got '%s'", rec.Header().Get("Age"))
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	if rec := serveCache(router, "/test"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected entry to expire")
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	store := NewCacheStore("1MB")
+	var mu sync.Mutex
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+
+	version := 0
+	refreshed := make(chan struct{}, 1)
+	router := fuselage.New()
+	router.Use(CacheWithConfig(CacheConfig{Store: store}))
+	router.GET("/feed", func(c *fuselage.Context) error {
+		mu.Lock()
+		version++
+		v := version
+		mu.Unlock()
+		if v > 1 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		c.SetHeader("Cache-Control", "max-age=60, stale-while-revalidate=300")
+		return c.String(http.StatusOK, "v"+strconv.Itoa(v))
+	})
+
+	serveCache(router, "/feed")
+	mu.Lock()
+	clock = clock.Add(2 * time.Minute)
+	mu.Unlock()
+
+	rec := serveCache(router, "/feed")
+	if rec.Header().Get("X-Cache") != "STALE" || rec.Body.String() != "v1" {
+		t.Fatalf("Expected stale v1, got '%s' '%s'", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected background revalidation")
+	}
+	// The refreshed entry is stored after the handler returns
+	deadline := time.Now().Add(time.Second)
+	for {
+		rec = serveCache(router, "/feed")
+		if rec.Header().Get("X-Cache") == "HIT" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rec.Header().Get("X-Cache") != "HIT" || rec.Body.String() != "v2" {
+		t.Errorf("Expected fresh v2, got '%s' '%s'", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+}
+
+func TestCacheStoreCloseWaitsForRevalidation(t *testing.T) {
+	store := NewCacheStore("1MB")
+	var mu sync.Mutex
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+
+	calls := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := fuselage.New()
+	router.Use(CacheWithConfig(CacheConfig{Store: store}))
+	router.GET("/feed", func(c *fuselage.Context) error {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n == 2 {
+			close(started)
+			<-release
+		}
+		c.SetHeader("Cache-Control", "max-age=60, stale-while-revalidate=300")
+		return c.String(http.StatusOK, "OK")
+	})
+
+	serveCache(router, "/feed")
+	mu.Lock()
+	clock = clock.Add(2 * time.Minute)
+	mu.Unlock()
+	serveCache(router, "/feed")
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		_ = store.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Expected Close to wait for the running revalidation")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to return after the revalidation finished")
+	}
+
+	// A closed store still serves stale entries but no longer refreshes them
+	mu.Lock()
+	clock = clock.Add(2 * time.Minute)
+	mu.Unlock()
+	if rec := serveCache(router, "/feed"); rec.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("Expected stale entry, got '%s'", rec.Header().Get("X-Cache"))
+	}
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 {
+		t.Errorf("Expected no revalidation after Close, got %d handler calls", calls)
+	}
+}
+
+func TestCacheInvalidatePrefix(t *testing.T) {
+	store := NewCacheStore("1MB")
+	router := fuselage.New()
+	router.Use(CacheWithConfig(CacheConfig{Store: store}))
+	router.GET("/users/:id", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+	router.GET("/posts", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "posts")
+	})
+
+	serveCache(router, "/users/1")
+	serveCache(router, "/users/2")
+	serveCache(router, "/posts")
+
+	if removed := store.InvalidatePrefix("/users/"); removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+	if rec := serveCache(router, "/users/1"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected invalidated entry to miss")
+	}
+	if rec := serveCache(router, "/posts"); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected other entries to remain")
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	store := NewCacheStore("3KB")
+	router := fuselage.New()
+	router.Use(CacheWithConfig(CacheConfig{Store: store}))
+	router.GET("/:name", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, strings.Repeat("x", 1000))
+	})
+
+	serveCache(router, "/a")
+	serveCache(router, "/b")
+	serveCache(router, "/a")
+	serveCache(router, "/c")
+	serveCache(router, "/d")
+
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 entries within the size bound, got %d", store.Len())
+	}
+	if rec := serveCache(router, "/a"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+}
+
+func TestCacheInFrontOfAuth(t *testing.T) {
+	calls := 0
+	router := fuselage.New()
+	router.Use(Cache())
+
+	admin := router.Group("/admin", BasicAuth(func(user, password string, c *fuselage.Context) (bool, error) {
+		return SecureCompare(user, "admin") && SecureCompare(password, "secret"), nil
+	}))
+	admin.GET("/report", func(c *fuselage.Context) error {
+		calls++
+		return c.String(http.StatusOK, "confidential "+strconv.Itoa(calls))
+	})
+	admin.GET("/public", func(c *fuselage.Context) error {
+		calls++
+		c.SetHeader("Cache-Control", "public, max-age=60")
+		return c.String(http.StatusOK, "shared")
+	})
+
+	credentials := "Basic YWRtaW46c2VjcmV0" // admin:secret
+	rec := serveCache(router, "/admin/report", "Authorization", credentials)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	rec = serveCache(router, "/admin/report")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected authenticated response not to be served to anonymous clients, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	rec = serveCache(router, "/admin/report", "Authorization", credentials)
+	if rec.Header().Get("X-Cache") == "HIT" || rec.Body.String() != "confidential 2" {
+		t.Errorf("Expected authenticated requests to bypass the cache, got '%s' '%s'", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+
+	// Responses marked public may be shared
+	_ = serveCache(router, "/admin/public", "Authorization", credentials)
+	rec = serveCache(router, "/admin/public", "Authorization", credentials)
+	if rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected public response to be cached, got '%s'", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheSkipsCookieRequests(t *testing.T) {
+	calls := 0
+	router := fuselage.New()
+	router.Use(Cache())
+
+	router.GET("/dashboard", func(c *fuselage.Context) error {
+		calls++
+		return c.String(http.StatusOK, "dashboard "+strconv.Itoa(calls))
+	})
+
+	_ = serveCache(router, "/dashboard")
+	rec := serveCache(router, "/dashboard", "Cookie", "session=abc")
+	if rec.Header().Get("X-Cache") == "HIT" {
+		t.Errorf("Expected request with cookies to bypass the cache")
+	}
+	_ = serveCache(router, "/dashboard", "Cookie", "session=abc")
+	rec = serveCache(router, "/dashboard")
+	if rec.Body.String() != "dashboard 1" {
+		t.Errorf("Expected responses to cookie requests not to be stored, got '%s'", rec.Body.String())
+	}
+}
+
+func TestCacheLetsStreamsThrough(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Cache())
+	_ = router.WebSocket("/ws", func(c *fuselage.Context, conn *fuselage.WebSocketConn) error {
+		return nil
+	})
+	router.GET("/events", func(c *fuselage.Context) error {
+		stream, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		return stream.Send("", "", "hello")
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest("GET", server.URL+"/ws", http.NoBody)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("Failed to write handshake: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected status 101, got %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest("GET", server.URL+"/events", http.NoBody)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	events, _ := fuselage.ParseSSE(resp.Body)
+	if resp.StatusCode != http.StatusOK || len(events) != 1 || events[0].Data != "hello" {
+		t.Errorf("Expected event stream, got %d %+v", resp.StatusCode, events)
+	}
+}
@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+type CompressConfig struct {
+	// Level is the compression level, 1-9 or flate.HuffmanOnly (default: flate.DefaultCompression)
+	Level int
+	// MinLength is the minimum body size in bytes worth compressing
+	MinLength int
+	// ExcludedContentTypes lists content type prefixes that are already compressed
+	ExcludedContentTypes []string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+}
+
+var DefaultCompressConfig = CompressConfig{
+	Level:     flate.DefaultCompression,
+	MinLength: 1024,
+	ExcludedContentTypes: []string{
+		"image/", "video/", "audio/", "font/woff",
+		"application/zip", "application/gzip", "application/x-gzip",
+		"application/zstd", "application/x-7z-compressed", "application/x-rar-compressed",
+	},
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+}
+
+func Compress() fuselage.MiddlewareFunc {
+	return CompressWithConfig(DefaultCompressConfig)
+}
+
+// CompressWithConfig compresses responses with gzip or deflate based on Accept-Encoding
+func CompressWithConfig(config CompressConfig) fuselage.MiddlewareFunc {
+	// Zero means unset; flate.NoCompression would send stored blocks labeled as compressed
+	if config.Level == 0 || config.Level < flate.HuffmanOnly || config.Level > flate.BestCompression {
+		config.Level = DefaultCompressConfig.Level
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultCompressConfig.MinLength
+	}
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = DefaultCompressConfig.ExcludedContentTypes
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultCompressConfig.Skipper
+	}
+
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, config.Level)
+			return w
+		}},
+		// HTTP "deflate" is the zlib format (RFC 9110 8.4.1.2), not raw DEFLATE
+		"deflate": {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(io.Discard, config.Level)
+			return w
+		}},
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			addVary(c, fuselage.HeaderAcceptEncoding)
+
+			encoding := negotiateEncoding(c.Header(fuselage.HeaderAcceptEncoding))
+			if encoding == "" || c.Request.Method == fuselage.HEAD {
+				return next(c)
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: c.Response.Writer,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				config:         &config,
+			}
+			c.Response.Writer = cw
+			defer func() {
+				cw.close()
+				c.Response.Writer = cw.ResponseWriter
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// compressor is implemented by gzip.Writer and zlib.Writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressWriter buffers the start of the body until it can decide whether to compress
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	config   *CompressConfig
+
+	status     int
+	buf        []byte
+	decided    bool
+	compressor compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		_ = w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.config.MinLength {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.compressor != nil {
+		return w.compressor.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) Flush() {
+	_ = w.FlushError()
+}
+
+func (w *compressWriter) FlushError() error {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		// Streaming responses are compressed regardless of the size seen so far
+		if err := w.decide(true); err != nil {
+			return err
+		}
+	}
+	if w.compressor != nil {
+		if err := w.compressor.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the header, choosing compression if eligible, then writes the buffered body
+func (w *compressWriter) decide(eligibleSize bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+
+	if len(w.buf) > 0 && header.Get(fuselage.HeaderContentType) == "" {
+		header.Set(fuselage.HeaderContentType, http.DetectContentType(w.buf))
+	}
+
+	compress := eligibleSize &&
+		w.status != http.StatusNoContent &&
+		w.status != http.StatusNotModified &&
+		w.status != http.StatusPartialContent &&
+		header.Get(fuselage.HeaderContentEncoding) == "" &&
+		!w.excluded(header.Get(fuselage.HeaderContentType))
+
+	if compress {
+		header.Set(fuselage.HeaderContentEncoding, w.encoding)
+		header.Del(fuselage.HeaderContentLength)
+		w.compressor = w.pool.Get().(compressor)
+		w.compressor.Reset(w.ResponseWriter)
+	} else if len(w.buf) > 0 && header.Get(fuselage.HeaderContentLength) == "" {
+		header.Set(fuselage.HeaderContentLength, strconv.Itoa(len(w.buf)))
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.compressor != nil {
+		_, err := w.compressor.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		_ = w.decide(len(w.buf) >= w.config.MinLength)
+	}
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+		w.compressor.Reset(io.Discard)
+		w.pool.Put(w.compressor)
+		w.compressor = nil
+	}
+}
+
+func (w *compressWriter) excluded(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range w.config.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	wildcardQ := -1.0
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch name {
+		case "gzip", "x-gzip":
+			name = "gzip"
+		case "deflate":
+		case "*":
+			wildcardQ = q
+			continue
+		default:
+			continue
+		}
+		seen[name] = true
+		// Prefer gzip on ties since it is the most widely supported
+		if q > 0 && (q > bestQ || (q == bestQ && name == "gzip")) {
+			best, bestQ = name, q
+		}
+	}
+
+	if best == "" && wildcardQ > 0 {
+		for _, name := range []string{"gzip", "deflate"} {
+			if !seen[name] {
+				return name
+			}
+		}
+	}
+	return best
+}
+
+// addVary appends a value to the Vary header unless it is already present
+func addVary(c *fuselage.Context, value string) {
+	header := c.Response.Header()
+	for _, v := range header.Values(fuselage.HeaderVary) {
+		for _, existing := range strings.Split(v, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, value) {
+				return
+			}
+		}
+	}
+	header.Add(fuselage.HeaderVary, value)
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"id":1,"name":"fuselage"},`, 100)
+
+	router := fuselage.New()
+	router.Use(Compress())
+
+	router.GET("/large", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, body)
+	})
+	router.GET("/small", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "tiny")
+	})
+
+	req := httptest.NewRequest("GET", "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got '%s'", rec.Header().Get("Vary"))
+	}
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip body: %v", err)
+	}
+	decoded, _ := io.ReadAll(gr)
+	if string(decoded) != body {
+		t.Errorf("Decoded body does not match")
+	}
+
+	req = httptest.NewRequest("GET", "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "tiny" {
+		t.Errorf("Expected small body to be sent uncompressed")
+	}
+}
+
+func TestCompressDeflate(t *testing.T) {
+	body := strings.Repeat("deflate me ", 200)
+
+	router := fuselage.New()
+	router.Use(Compress())
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, body)
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.5, deflate")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("Expected deflate encoding, got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := zlib.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Expected zlib-wrapped deflate body: %v", err)
+	}
+	decoded, _ := io.ReadAll(zr)
+	if string(decoded) != body {
+		t.Errorf("Decoded body does not match")
+	}
+}
+
+func TestCompressSkipsCompressedTypes(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Compress())
+
+	router.GET("/image", func(c *fuselage.Context) error {
+		c.SetHeader("Content-Type", "image/png")
+		c.SetStatus(http.StatusOK)
+		_, err := c.Response.Write(make([]byte, 4096))
+		return err
+	})
+
+	req := httptest.NewRequest("GET", "/image", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected image to be sent uncompressed")
+	}
+	if rec.Body.Len() != 4096 {
+		t.Errorf("Expected 4096 bytes, got %d", rec.Body.Len())
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Compress())
+
+	flushed := false
+	router.GET("/stream", func(c *fuselage.Context) error {
+		c.SetHeader("Content-Type", "text/plain")
+		_, _ = c.Response.Write([]byte("chunk"))
+		c.Response.Flush()
+		flushed = true
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if !flushed || !rec.Flushed {
+		t.Fatalf("Expected flush to reach the underlying writer")
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected streamed response to be compressed")
+	}
+	gr, _ := gzip.NewReader(rec.Body)
+	decoded, _ := io.ReadAll(gr)
+	if string(decoded) != "chunk" {
+		t.Errorf("Expected 'chunk', got '%s'", decoded)
+	}
+}
+
+func TestCompressWithoutAcceptEncoding(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Compress())
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, strings.Repeat("x", 2048))
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 2048 {
+		t.Errorf("Expected identity response")
+	}
+}
+
+func TestCompressPartialConfigCompresses(t *testing.T) {
+	body := strings.Repeat("partial config ", 200)
+
+	router := fuselage.New()
+	router.Use(CompressWithConfig(CompressConfig{MinLength: 256}))
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, body)
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+	// Stored (level 0) blocks would be larger than the input
+	if rec.Body.Len() >= len(body)/2 {
+		t.Errorf("Expected body to be compressed, got %d bytes for %d input", rec.Body.Len(), len(body))
+	}
+	gr, _ := gzip.NewReader(rec.Body)
+	decoded, _ := io.ReadAll(gr)
+	if string(decoded) != body {
+		t.Errorf("Decoded body does not match")
+	}
+}
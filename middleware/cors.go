@@ -80,7 +80,7 @@ func CORSWithConfig(config *CORSConfig) fuselage.MiddlewareFunc {
 			origin := c.Header(fuselage.HeaderOrigin)
 			allow := false
 
-			c.SetHeader(fuselage.HeaderVary, fuselage.HeaderOrigin)
+			addVary(c, fuselage.HeaderOrigin)
 
 			preflight := c.Request.Method == fuselage.OPTIONS
 
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+	"github.com/k-tsurumaki/fuselage/middleware/session"
+)
+
+// CSRFMode selects how the expected token is stored
+type CSRFMode int
+
+const (
+	// CSRFDoubleSubmitCookie stores the token in a cookie and compares it with the submitted token
+	CSRFDoubleSubmitCookie CSRFMode = iota
+	// CSRFSynchronizerToken stores the token in the server-side session (requires session middleware)
+	CSRFSynchronizerToken
+)
+
+var (
+	ErrCSRFTokenMissing   = errors.New("missing CSRF token")
+	ErrCSRFTokenInvalid   = errors.New("invalid CSRF token")
+	ErrCSRFOriginMismatch = errors.New("cross-origin request rejected")
+	ErrCSRFNoSession      = errors.New("CSRF synchronizer mode requires session middleware")
+)
+
+type CSRFConfig struct {
+	// Mode selects double-submit cookie or synchronizer token storage
+	Mode CSRFMode
+	// TokenLength is the number of random bytes in a token
+	TokenLength int
+	// TokenLookup defines where submitted tokens are read from,
+	// e.g. "header:X-CSRF-Token,form:_csrf,query:_csrf"
+	TokenLookup string
+	// CookieName is the cookie holding the token in double-submit mode
+	CookieName string
+	// CookiePath is the path attribute of the token cookie
+	CookiePath string
+	// CookieDomain is the domain attribute of the token cookie
+	CookieDomain string
+	// CookieMaxAge is the lifetime of the token cookie in seconds
+	CookieMaxAge int
+	// CookieInsecure allows the token cookie over plain HTTP (development only)
+	CookieInsecure bool
+	// SessionKey is the session value holding the token in synchronizer mode
+	SessionKey string
+	// TrustedOrigins lists additional origins allowed to send unsafe requests
+	TrustedOrigins []string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles rejected requests
+	ErrorHandler func(*fuselage.Context, error) error
+}
+
+var DefaultCSRFConfig = CSRFConfig{
+	Mode:         CSRFDoubleSubmitCookie,
+	TokenLength:  32,
+	TokenLookup:  "header:" + fuselage.HeaderXCSRFToken + ",form:_csrf",
+	CookieName:   "_csrf",
+	CookiePath:   "/",
+	CookieMaxAge: 86400,
+	SessionKey:   "csrf_token",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		if errors.Is(err, ErrCSRFNoSession) {
+			return c.String(http.StatusInternalServerError, "Internal Server Error")
+		}
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+func CSRF() fuselage.MiddlewareFunc {
+	return CSRFWithConfig(DefaultCSRFConfig)
+}
+
+// CSRFWithConfig protects unsafe methods against cross-site request forgery
+func CSRFWithConfig(config CSRFConfig) fuselage.MiddlewareFunc {
+	if config.TokenLength <= 0 {
+		config.TokenLength = DefaultCSRFConfig.TokenLength
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultCSRFConfig.TokenLookup
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCSRFConfig.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultCSRFConfig.CookiePath
+	}
+	if config.CookieMaxAge == 0 {
+		config.CookieMaxAge = DefaultCSRFConfig.CookieMaxAge
+	}
+	if config.SessionKey == "" {
+		config.SessionKey = DefaultCSRFConfig.SessionKey
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultCSRFConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultCSRFConfig.ErrorHandler
+	}
+
+	extractors := mustCreateExtractors(config.TokenLookup)
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			token, err := csrfToken(c, &config)
+			if err != nil {
+				return config.ErrorHandler(c, err)
+			}
+
+			ctx := context.WithValue(c.Request.Context(), fuselage.CSRFTokenKey, token)
+			c.Request = c.Request.WithContext(ctx)
+			addVary(c, fuselage.HeaderCookie)
+
+			switch c.Request.Method {
+			case fuselage.GET, fuselage.HEAD, fuselage.OPTIONS, fuselage.TRACE:
+				return next(c)
+			}
+
+			if err := checkCSRFOrigin(c, config.TrustedOrigins); err != nil {
+				return config.ErrorHandler(c, err)
+			}
+
+			submitted := extractValue(c, extractors)
+			if submitted == "" {
+				return config.ErrorHandler(c, ErrCSRFTokenMissing)
+			}
+			if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+				return config.ErrorHandler(c, ErrCSRFTokenInvalid)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GetCSRFToken returns the token to embed in forms or headers
+func GetCSRFToken(c *fuselage.Context) string {
+	if token, ok := c.Request.Context().Value(fuselage.CSRFTokenKey).(string); ok {
+		return token
+	}
+	return ""
+}
+
+func csrfToken(c *fuselage.Context, config *CSRFConfig) (string, error) {
+	if config.Mode == CSRFSynchronizerToken {
+		s := session.FromContext(c)
+		if s == nil {
+			return "", ErrCSRFNoSession
+		}
+		if token, ok := s.Get(config.SessionKey).(string); ok && token != "" {
+			return token, nil
+		}
+		token := generateCSRFToken(config.TokenLength)
+		s.Set(config.SessionKey, token)
+		return token, nil
+	}
+
+	if cookie, err := c.Cookie(config.CookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	token := generateCSRFToken(config.TokenLength)
+	cookie := fuselage.NewCookie(config.CookieName, token)
+	cookie.Path = config.CookiePath
+	cookie.Domain = config.CookieDomain
+	cookie.MaxAge = config.CookieMaxAge
+	cookie.Secure = !config.CookieInsecure
+	// Scripts need to read the cookie to echo it in a header
+	cookie.HttpOnly = false
+	cookie.SameSite = http.SameSiteStrictMode
+	c.SetCookie(cookie)
+	return token, nil
+}
+
+// checkCSRFOrigin rejects unsafe requests whose Sec-Fetch-Site, Origin or
+// Referer shows they were issued by another site
+func checkCSRFOrigin(c *fuselage.Context, trusted []string) error {
+	origin := c.Header(fuselage.HeaderOrigin)
+
+	switch c.Header(fuselage.HeaderSecFetchSite) {
+	case "", "same-origin", "none":
+	default:
+		if origin == "" || !containsOrigin(trusted, origin) {
+			return ErrCSRFOriginMismatch
+		}
+	}
+
+	scheme := c.Scheme()
+	self := scheme + "://" + c.Host()
+
+	if origin != "" {
+		if strings.EqualFold(origin, self) || containsOrigin(trusted, origin) {
+			return nil
+		}
+		return ErrCSRFOriginMismatch
+	}
+
+	// Browsers may omit Origin; over TLS require a same-origin Referer instead
+	referer := c.Header(fuselage.HeaderReferer)
+	if referer == "" {
+		if scheme == "https" {
+			return ErrCSRFOriginMismatch
+		}
+		return nil
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ErrCSRFOriginMismatch
+	}
+	refererOrigin := u.Scheme + "://" + u.Host
+	if strings.EqualFold(refererOrigin, self) || containsOrigin(trusted, refererOrigin) {
+		return nil
+	}
+	return ErrCSRFOriginMismatch
+}
+
+func containsOrigin(list []string, origin string) bool {
+	for _, o := range list {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateCSRFToken(length int) string {
+	bytes := make([]byte, length)
+	_, _ = rand.Read(bytes)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+	"github.com/k-tsurumaki/fuselage/middleware/session"
+)
+
+func TestCSRF(t *testing.T) {
+	router := fuselage.New()
+	router.Use(CSRF())
+
+	router.GET("/form", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetCSRFToken(c))
+	})
+	router.POST("/form", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	// GET issues a token cookie and exposes the token
+	req := httptest.NewRequest("GET", "/form", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != rec.Body.String() {
+		t.Fatalf("Expected token cookie matching the body")
+	}
+	token := cookies[0]
+
+	// POST without a token is rejected
+	req = httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rec.Code)
+	}
+
+	// POST with the token in the header passes
+	req = httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(token)
+	req.Header.Set(fuselage.HeaderXCSRFToken, token.Value)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	// POST with the token in a form field passes
+	form := url.Values{"_csrf": {token.Value}}
+	req = httptest.NewRequest("POST", "/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+}
+
+func TestCSRFCrossOrigin(t *testing.T) {
+	router := fuselage.New()
+	router.Use(CSRFWithConfig(CSRFConfig{
+		TrustedOrigins: []string{"https://partner.example.com"},
+	}))
+
+	router.POST("/form", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"foreign origin", map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"foreign referer", map[string]string{"Referer": "https://evil.example.com/page"}, http.StatusForbidden},
+		{"same origin", map[string]string{"Origin": "http://example.com"}, http.StatusOK},
+		{"trusted origin", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://partner.example.com"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/form", nil)
+		req.AddCookie(&http.Cookie{Name: "_csrf", Value: "token"})
+		req.Header.Set(fuselage.HeaderXCSRFToken, "token")
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+		}
+	}
+}
+
+func TestCSRFWithSkipper(t *testing.T) {
+	router := fuselage.New()
+	router.Use(CSRFWithConfig(CSRFConfig{
+		Skipper: func(c *fuselage.Context) bool {
+			return c.Request.URL.Path == "/webhook"
+		},
+	}))
+
+	router.POST("/webhook", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := httptest.NewRequest("POST", "/webhook", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+}
+
+func TestCSRFSynchronizerToken(t *testing.T) {
+	router := fuselage.New()
+	router.Use(session.MiddlewareWithConfig(session.Config{CookieInsecure: true}))
+	router.Use(CSRFWithConfig(CSRFConfig{Mode: CSRFSynchronizerToken}))
+
+	router.GET("/form", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetCSRFToken(c))
+	})
+	router.POST("/form", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := httptest.NewRequest("GET", "/form", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	token := rec.Body.String()
+	cookies := rec.Result().Cookies()
+	if token == "" || len(cookies) != 1 || cookies[0].Name != "session_id" {
+		t.Fatalf("Expected token stored in the session only")
+	}
+
+	req = httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set(fuselage.HeaderXCSRFToken, token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set(fuselage.HeaderXCSRFToken, "forged")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rec.Code)
+	}
+}
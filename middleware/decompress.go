@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+var (
+	ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+	ErrInvalidCompressedBody      = errors.New("invalid compressed request body")
+)
+
+type DecompressConfig struct {
+	// MaxSize is the maximum decompressed body size, guarding against
+	// decompression bombs, e.g. "32MB" (units are powers of 1024)
+	MaxSize string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles bodies that cannot be decoded
+	ErrorHandler func(*fuselage.Context, error) error
+}
+
+var DefaultDecompressConfig = DecompressConfig{
+	MaxSize: "32MB",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrUnsupportedContentEncoding) {
+			status = http.StatusUnsupportedMediaType
+		}
+		return c.JSON(status, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+func Decompress() fuselage.MiddlewareFunc {
+	return DecompressWithConfig(DefaultDecompressConfig)
+}
+
+// DecompressWithConfig transparently decodes gzip and deflate request bodies.
+// Reads past MaxSize fail with fuselage.ErrRequestEntityTooLarge.
+// It panics if MaxSize is not a valid size.
+func DecompressWithConfig(config DecompressConfig) fuselage.MiddlewareFunc {
+	if config.MaxSize == "" {
+		config.MaxSize = DefaultDecompressConfig.MaxSize
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultDecompressConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultDecompressConfig.ErrorHandler
+	}
+
+	maxSize, err := ParseSize(config.MaxSize)
+	if err != nil {
+		panic("fuselage: " + err.Error())
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) || c.Request.Body == nil || c.Request.Body == http.NoBody {
+				return next(c)
+			}
+
+			encoding := strings.ToLower(strings.TrimSpace(c.Header(fuselage.HeaderContentEncoding)))
+			var reader io.ReadCloser
+			switch encoding {
+			case "", "identity":
+				return next(c)
+			case "gzip", "x-gzip":
+				gr, err := gzip.NewReader(c.Request.Body)
+				if err != nil {
+					return config.ErrorHandler(c, ErrInvalidCompressedBody)
+				}
+				reader = gr
+			case "deflate":
+				dr, err := newDeflateReader(c.Request.Body)
+				if err != nil {
+					return config.ErrorHandler(c, ErrInvalidCompressedBody)
+				}
+				reader = dr
+			default:
+				return config.ErrorHandler(c, ErrUnsupportedContentEncoding)
+			}
+
+			original := c.Request.Body
+			defer func() {
+				_ = reader.Close()
+				_ = original.Close()
+			}()
+
+			c.Request.Body = &limitedBody{ReadCloser: reader, remaining: maxSize}
+			c.Request.Header.Del(fuselage.HeaderContentEncoding)
+			c.Request.Header.Del(fuselage.HeaderContentLength)
+			c.Request.ContentLength = -1
+
+			return next(c)
+		}
+	}
+}
+
+// newDeflateReader accepts both zlib-wrapped deflate (as specified for HTTP)
+// and the raw deflate streams some clients send instead
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func echoBody(c *fuselage.Context) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	return c.String(http.StatusOK, string(body))
+}
+
+func TestDecompress(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Decompress())
+	router.POST("/ingest", echoBody)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write([]byte(`{"event":"signup"}`))
+	_ = gw.Close()
+
+	req := httptest.NewRequest("POST", "/ingest", &gz)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"event":"signup"}` {
+		t.Errorf("Expected decoded body, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/ingest", strings.NewReader("plain"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "plain" {
+		t.Errorf("Expected identity body to pass through, got '%s'", rec.Body.String())
+	}
+}
+
+func TestDecompressDeflate(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Decompress())
+	router.POST("/ingest", echoBody)
+
+	var zl bytes.Buffer
+	zw := zlib.NewWriter(&zl)
+	_, _ = zw.Write([]byte("zlib wrapped"))
+	_ = zw.Close()
+
+	var raw bytes.Buffer
+	fw, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	_, _ = fw.Write([]byte("raw deflate"))
+	_ = fw.Close()
+
+	for expected, body := range map[string]*bytes.Buffer{"zlib wrapped": &zl, "raw deflate": &raw} {
+		req := httptest.NewRequest("POST", "/ingest", body)
+		req.Header.Set("Content-Encoding", "deflate")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Body.String() != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, rec.Body.String())
+		}
+	}
+}
+
+func TestDecompressBombGuard(t *testing.T) {
+	router := fuselage.New()
+	router.Use(DecompressWithConfig(DecompressConfig{MaxSize: "64KB"}))
+	router.POST("/ingest", echoBody)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write(make([]byte, 1<<20))
+	_ = gw.Close()
+
+	req := httptest.NewRequest("POST", "/ingest", &gz)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rec.Code)
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Decompress())
+	router.POST("/ingest", echoBody)
+
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/ingest", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "br")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415, got %d", rec.Code)
+	}
+}
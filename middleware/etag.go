@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+type ETagConfig struct {
+	// Weak generates weak validators (W/"...") instead of strong ones
+	Weak bool
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+}
+
+var DefaultETagConfig = ETagConfig{
+	Weak: false,
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+}
+
+func ETag() fuselage.MiddlewareFunc {
+	return ETagWithConfig(DefaultETagConfig)
+}
+
+// ETagWithConfig buffers GET and HEAD responses, tags successful ones with a
+// hash of the body unless the handler set an ETag itself, and answers
+// If-None-Match and If-Modified-Since with 304 Not Modified.
+// Streaming and upgraded responses are passed through unbuffered.
+func ETagWithConfig(config ETagConfig) fuselage.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultETagConfig.Skipper
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) || !bufferable(c) {
+				return next(c)
+			}
+
+			buf, err := bufferResponse(c, next, c.Response.Header())
+			if buf.status == 0 {
+				return err
+			}
+
+			if buf.status == http.StatusOK && c.Response.Header().Get(fuselage.HeaderETag) == "" {
+				c.SetETag(hashETag(buf.body.Bytes()), config.Weak)
+			}
+			if buf.status == http.StatusOK && c.CheckPreconditions() {
+				return err
+			}
+
+			if writeErr := buf.writeTo(c.Response); err == nil {
+				err = writeErr
+			}
+			return err
+		}
+	}
+}
+
+// bufferable reports whether the response can be held in memory before sending
+func bufferable(c *fuselage.Context) bool {
+	if c.Request.Method != fuselage.GET && c.Request.Method != fuselage.HEAD {
+		return false
+	}
+	if c.Header(fuselage.HeaderUpgrade) != "" {
+		return false
+	}
+	return !strings.Contains(c.Header(fuselage.HeaderAccept), "text/event-stream")
+}
+
+// responseBuffer captures a response so it can be inspected before sending
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// writeTo sends the captured status and body
+func (b *responseBuffer) writeTo(w http.ResponseWriter) error {
+	if b.body.Len() > 0 && w.Header().Get(fuselage.HeaderContentLength) == "" {
+		w.Header().Set(fuselage.HeaderContentLength, strconv.Itoa(b.body.Len()))
+	}
+	w.WriteHeader(b.status)
+	_, err := w.Write(b.body.Bytes())
+	return err
+}
+
+// bufferResponse runs next with the response captured in memory, using header
+// as the header map, and restores the real response writer afterwards
+func bufferResponse(c *fuselage.Context, next fuselage.HandlerFunc, header http.Header) (*responseBuffer, error) {
+	original := c.Response
+	buf := &responseBuffer{header: header}
+	c.Response = fuselage.NewResponseWriter(buf)
+	defer func() {
+		c.Response = original
+	}()
+	err := next(c)
+	return buf, err
+}
+
+func hashETag(body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	return strconv.FormatInt(int64(len(body)), 16) + "-" + strconv.FormatUint(h.Sum64(), 16)
+}
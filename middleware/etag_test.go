@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func TestETag(t *testing.T) {
+	calls := 0
+	router := fuselage.New()
+	router.Use(ETag())
+
+	router.GET("/users", func(c *fuselage.Context) error {
+		calls++
+		return c.JSON(http.StatusOK, []string{"alice", "bob"})
+	})
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("Expected strong ETag on 200, got %d '%s'", rec.Code, etag)
+	}
+	if !strings.Contains(rec.Body.String(), "alice") {
+		t.Errorf("Expected body to be sent")
+	}
+
+	req = httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got '%s'", rec.Body.String())
+	}
+	if rec.Header().Get("ETag") != etag {
+		t.Errorf("Expected ETag on 304 response")
+	}
+	if calls != 2 {
+		t.Errorf("Expected handler to run for each request, got %d", calls)
+	}
+}
+
+func TestETagWeak(t *testing.T) {
+	router := fuselage.New()
+	router.Use(ETagWithConfig(ETagConfig{Weak: true}))
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+	router.GET("/missing", func(c *fuselage.Context) error {
+		return c.String(http.StatusNotFound, "gone")
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if !strings.HasPrefix(rec.Header().Get("ETag"), `W/"`) {
+		t.Errorf("Expected weak ETag, got '%s'", rec.Header().Get("ETag"))
+	}
+
+	req = httptest.NewRequest("GET", "/missing", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("ETag") != "" || rec.Code != http.StatusNotFound || rec.Body.String() != "gone" {
+		t.Errorf("Expected error responses to pass through untagged")
+	}
+}
+
+func TestETagHandlerTag(t *testing.T) {
+	router := fuselage.New()
+	router.Use(ETag())
+
+	router.GET("/doc", func(c *fuselage.Context) error {
+		c.SetETag("rev-7", false)
+		if c.CheckPreconditions() {
+			return nil
+		}
+		return c.String(http.StatusOK, "expensive")
+	})
+
+	req := httptest.NewRequest("GET", "/doc", nil)
+	req.Header.Set("If-None-Match", `"rev-7"`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", rec.Code)
+	}
+	if rec.Header().Get("ETag") != `"rev-7"` {
+		t.Errorf("Expected handler ETag to be kept, got '%s'", rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// valueExtractor reads a value from one part of the request
+type valueExtractor func(*fuselage.Context) string
+
+// createExtractors parses a lookup such as "header:X-CSRF-Token,form:_csrf"
+// into extractors tried in order. Supported sources are header, query, form and cookie.
+func createExtractors(lookup string) ([]valueExtractor, error) {
+	var extractors []valueExtractor
+
+	for _, part := range strings.Split(lookup, ",") {
+		source, name, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid lookup %q", part)
+		}
+
+		switch source {
+		case "header":
+			extractors = append(extractors, func(c *fuselage.Context) string {
+				return c.Header(name)
+			})
+		case "query":
+			extractors = append(extractors, func(c *fuselage.Context) string {
+				return c.Query(name)
+			})
+		case "form":
+			extractors = append(extractors, func(c *fuselage.Context) string {
+				return c.Request.PostFormValue(name)
+			})
+		case "cookie":
+			extractors = append(extractors, func(c *fuselage.Context) string {
+				if cookie, err := c.Cookie(name); err == nil {
+					return cookie.Value
+				}
+				return ""
+			})
+		default:
+			return nil, fmt.Errorf("unsupported lookup source %q", source)
+		}
+	}
+
+	return extractors, nil
+}
+
+func mustCreateExtractors(lookup string) []valueExtractor {
+	extractors, err := createExtractors(lookup)
+	if err != nil {
+		panic("fuselage: " + err.Error())
+	}
+	return extractors
+}
+
+func extractValue(c *fuselage.Context, extractors []valueExtractor) string {
+	for _, extract := range extractors {
+		if v := extract(c); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+var (
+	ErrTokenMissing        = errors.New("missing bearer token")
+	ErrTokenInactive       = errors.New("token is not active")
+	ErrIntrospectionFailed = errors.New("token introspection failed")
+)
+
+// IntrospectionResult is an RFC 7662 introspection response for an active token
+type IntrospectionResult struct {
+	Active    bool
+	Scopes    []string
+	ClientID  string
+	Username  string
+	TokenType string
+	Subject   string
+	Audience  []string
+	Issuer    string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	// Raw holds every member of the response
+	Raw map[string]interface{}
+}
+
+type IntrospectionConfig struct {
+	// Endpoint is the introspection URL (required)
+	Endpoint string
+	// ClientID authenticates the resource server to the endpoint
+	ClientID string
+	// ClientSecret authenticates the resource server to the endpoint
+	ClientSecret string
+	// Client performs introspection requests
+	Client *http.Client
+	// TokenLookup defines where tokens are read from. A "Bearer " prefix is removed.
+	TokenLookup string
+	// CacheTTL caps how long active results are cached; they never outlive the token's exp
+	CacheTTL time.Duration
+	// MaxCacheEntries bounds the number of cached results
+	MaxCacheEntries int
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles missing, inactive and unverifiable tokens
+	ErrorHandler func(*fuselage.Context, error) error
+
+	now func() time.Time
+}
+
+var DefaultIntrospectionConfig = IntrospectionConfig{
+	Client:          &http.Client{Timeout: 10 * time.Second},
+	TokenLookup:     "header:" + fuselage.HeaderAuthorization,
+	CacheTTL:        5 * time.Minute,
+	MaxCacheEntries: 10000,
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		if errors.Is(err, ErrIntrospectionFailed) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		challenge := `Bearer error="invalid_token"`
+		if errors.Is(err, ErrTokenMissing) {
+			challenge = "Bearer"
+		}
+		c.SetHeader(fuselage.HeaderWWWAuthenticate, challenge)
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+// Introspection authenticates opaque bearer tokens against an RFC 7662 endpoint
+func Introspection(endpoint, clientID, clientSecret string) fuselage.MiddlewareFunc {
+	config := DefaultIntrospectionConfig
+	config.Endpoint = endpoint
+	config.ClientID = clientID
+	config.ClientSecret = clientSecret
+	return IntrospectionWithConfig(config)
+}
+
+// IntrospectionWithConfig authenticates opaque bearer tokens by introspection.
+// Active results are cached until the token expires, inactive tokens respond
+// 401 and endpoint failures 503. The result is available through
+// GetIntrospection, and a Principal is stored with the subject and scopes.
+// It panics if Endpoint is empty.
+func IntrospectionWithConfig(config IntrospectionConfig) fuselage.MiddlewareFunc {
+	if config.Endpoint == "" {
+		panic("fuselage: introspection middleware requires an endpoint")
+	}
+	if config.Client == nil {
+		config.Client = DefaultIntrospectionConfig.Client
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultIntrospectionConfig.TokenLookup
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DefaultIntrospectionConfig.CacheTTL
+	}
+	if config.MaxCacheEntries <= 0 {
+		config.MaxCacheEntries = DefaultIntrospectionConfig.MaxCacheEntries
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultIntrospectionConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultIntrospectionConfig.ErrorHandler
+	}
+	if config.now == nil {
+		config.now = time.Now
+	}
+
+	extractors := mustCreateExtractors(config.TokenLookup)
+	cache := &introspectionCache{
+		entries:    make(map[[sha256.Size]byte]introspectionEntry),
+		maxEntries: config.MaxCacheEntries,
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			token := extractValue(c, extractors)
+			if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+				token = strings.TrimSpace(token[7:])
+			}
+			if token == "" {
+				return config.ErrorHandler(c, ErrTokenMissing)
+			}
+
+			now := config.now()
+			key := sha256.Sum256([]byte(token))
+			result, ok := cache.get(key, now)
+			if !ok {
+				var err error
+				result, err = introspect(c.Request.Context(), &config, token)
+				if err != nil {
+					return config.ErrorHandler(c, err)
+				}
+				if !result.Active || (!result.ExpiresAt.IsZero() && !now.Before(result.ExpiresAt)) {
+					return config.ErrorHandler(c, ErrTokenInactive)
+				}
+				until := now.Add(config.CacheTTL)
+				if !result.ExpiresAt.IsZero() && result.ExpiresAt.Before(until) {
+					until = result.ExpiresAt
+				}
+				cache.set(key, result, until, now)
+			}
+
+			ctx := context.WithValue(c.Request.Context(), fuselage.IntrospectionKey, result)
+			c.Request = c.Request.WithContext(ctx)
+			id := result.Subject
+			if id == "" {
+				id = result.Username
+			}
+			SetPrincipal(c, &Principal{
+				ID:       id,
+				Scheme:   "bearer",
+				Scopes:   result.Scopes,
+				Metadata: result.Raw,
+			})
+			return next(c)
+		}
+	}
+}
+
+// GetIntrospection returns the introspection result of the request's token
+func GetIntrospection(c *fuselage.Context) *IntrospectionResult {
+	if result, ok := c.Request.Context().Value(fuselage.IntrospectionKey).(*IntrospectionResult); ok {
+		return result
+	}
+	return nil
+}
+
+func introspect(ctx context.Context, config *IntrospectionConfig, token string) (*IntrospectionResult, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospectionFailed, err)
+	}
+	req.Header.Set(fuselage.HeaderContentType, "application/x-www-form-urlencoded")
+	req.Header.Set(fuselage.HeaderAccept, "application/json")
+	if config.ClientID != "" {
+		// RFC 6749 section 2.3.1 requires form-encoding the credentials
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospectionFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: endpoint responded %d", ErrIntrospectionFailed, resp.StatusCode)
+	}
+	var raw map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospectionFailed, err)
+	}
+	return parseIntrospection(raw), nil
+}
+
+func parseIntrospection(raw map[string]interface{}) *IntrospectionResult {
+	str := func(name string) string {
+		s, _ := raw[name].(string)
+		return s
+	}
+	unix := func(name string) time.Time {
+		if seconds, ok := raw[name].(float64); ok {
+			return time.Unix(int64(seconds), 0)
+		}
+		return time.Time{}
+	}
+
+	result := &IntrospectionResult{
+		Scopes:    strings.Fields(str("scope")),
+		ClientID:  str("client_id"),
+		Username:  str("username"),
+		TokenType: str("token_type"),
+		Subject:   str("sub"),
+		Issuer:    str("iss"),
+		ExpiresAt: unix("exp"),
+		IssuedAt:  unix("iat"),
+		Raw:       raw,
+	}
+	result.Active, _ = raw["active"].(bool)
+	switch aud := raw["aud"].(type) {
+	case string:
+		result.Audience = []string{aud}
+	case []interface{}:
+		result.Audience = stringList(aud)
+	}
+	return result
+}
+
+type introspectionEntry struct {
+	result *IntrospectionResult
+	until  time.Time
+}
+
+// introspectionCache holds active results keyed by token digest
+type introspectionCache struct {
+	mu         sync.Mutex
+	entries    map[[sha256.Size]byte]introspectionEntry
+	maxEntries int
+}
+
+func (c *introspectionCache) get(key [sha256.Size]byte, now time.Time) (*IntrospectionResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.until) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func (c *introspectionCache) set(key [sha256.Size]byte, result *IntrospectionResult, until, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.until) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: drop arbitrary entries, which only costs a re-introspection
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = introspectionEntry{result: result, until: until}
+}
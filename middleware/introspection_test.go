@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func newIntrospectionServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "api" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.PostFormValue("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostFormValue("token") {
+		case "active-token":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"active":    true,
+				"sub":       "user-42",
+				"client_id": "web",
+				"scope":     "orders:read orders:write",
+				"aud":       "orders",
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			})
+		case "short-token":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"active": true,
+				"sub":    "user-7",
+				"exp":    time.Now().Add(2 * time.Second).Unix(),
+			})
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"active": false})
+		}
+	}))
+}
+
+func serveBearer(router *fuselage.Router, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/orders", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIntrospection(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(&calls)
+	defer server.Close()
+
+	router := fuselage.New()
+	router.Use(Introspection(server.URL, "api", "s3cret"))
+	router.GET("/orders", func(c *fuselage.Context) error {
+		result := GetIntrospection(c)
+		p := GetPrincipal(c)
+		return c.String(http.StatusOK, p.ID+" "+result.ClientID+" "+strings.Join(p.Scopes, ","))
+	}, RequireScopes("orders:read"))
+
+	rec := serveBearer(router, "active-token")
+	if rec.Code != http.StatusOK || rec.Body.String() != "user-42 web orders:read,orders:write" {
+		t.Fatalf("Expected active token, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	serveBearer(router, "active-token")
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected cached result, got %d introspection calls", calls)
+	}
+
+	rec = serveBearer(router, "revoked-token")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token"` {
+		t.Errorf("Expected 401 for inactive token, got %d '%s'", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	serveBearer(router, "revoked-token")
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected inactive tokens not to be cached, got %d calls", calls)
+	}
+
+	rec = serveBearer(router, "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("Expected 401 challenge without token, got %d", rec.Code)
+	}
+
+	rec = serveBearer(router, "broken")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the endpoint fails, got %d", rec.Code)
+	}
+}
+
+func TestIntrospectionCacheUntilExpiry(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(&calls)
+	defer server.Close()
+
+	now := time.Now()
+	router := fuselage.New()
+	router.Use(IntrospectionWithConfig(IntrospectionConfig{
+		Endpoint:     server.URL,
+		ClientID:     "api",
+		ClientSecret: "s3cret",
+		now:          func() time.Time { return now },
+	}))
+	router.GET("/orders", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetPrincipal(c).ID)
+	})
+
+	if rec := serveBearer(router, "short-token"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected active token, got %d", rec.Code)
+	}
+	serveBearer(router, "short-token")
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected cached result before exp, got %d calls", calls)
+	}
+
+	now = now.Add(5 * time.Second)
+	if rec := serveBearer(router, "short-token"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected expired token to be rejected, got %d", rec.Code)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Expected cache entry to end at exp, got %d calls", calls)
+	}
+}
+
+func TestIntrospectionClientCredentials(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(&calls)
+	defer server.Close()
+
+	router := fuselage.New()
+	router.Use(Introspection(server.URL, "api", "wrong"))
+	router.GET("/orders", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	if rec := serveBearer(router, "active-token"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the endpoint rejects client credentials, got %d", rec.Code)
+	}
+}
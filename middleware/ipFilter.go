@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+var ErrIPForbidden = errors.New("IP address not allowed")
+
+// IPRules decides whether a client address may pass the filter
+type IPRules interface {
+	Allowed(ip netip.Addr) bool
+}
+
+type IPFilterConfig struct {
+	// Allow lists CIDR ranges or addresses that may pass; when empty, every
+	// address not denied may pass. Used when Rules is nil.
+	Allow []string
+	// Deny lists CIDR ranges or addresses that are rejected, even if allowed.
+	// Used when Rules is nil.
+	Deny []string
+	// Rules overrides Allow and Deny, e.g. with rules reloaded from a file
+	Rules IPRules
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles rejected requests
+	ErrorHandler func(*fuselage.Context, error) error
+}
+
+var DefaultIPFilterConfig = IPFilterConfig{
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+// IPFilter only lets clients in the allowed ranges through
+func IPFilter(allow ...string) fuselage.MiddlewareFunc {
+	config := DefaultIPFilterConfig
+	config.Allow = allow
+	return IPFilterWithConfig(config)
+}
+
+// IPFilterWithConfig filters requests by client IP (Context.RealIP, which
+// honors forwarding headers from trusted proxies only)
+func IPFilterWithConfig(config IPFilterConfig) fuselage.MiddlewareFunc {
+	if config.Rules == nil {
+		rules, err := NewStaticIPRules(config.Allow, config.Deny)
+		if err != nil {
+			panic("fuselage: " + err.Error())
+		}
+		config.Rules = rules
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultIPFilterConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultIPFilterConfig.ErrorHandler
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			ip, err := netip.ParseAddr(c.RealIP())
+			if err != nil || !config.Rules.Allowed(ip.Unmap().WithZone("")) {
+				return config.ErrorHandler(c, ErrIPForbidden)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// StaticIPRules is a fixed set of allow and deny ranges
+type StaticIPRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewStaticIPRules parses allow and deny lists of CIDR ranges or addresses
+func NewStaticIPRules(allow, deny []string) (*StaticIPRules, error) {
+	rules := &StaticIPRules{}
+	for _, s := range allow {
+		prefix, err := fuselage.ParseIPPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q: %w", s, err)
+		}
+		rules.allow = append(rules.allow, prefix)
+	}
+	for _, s := range deny {
+		prefix, err := fuselage.ParseIPPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q: %w", s, err)
+		}
+		rules.deny = append(rules.deny, prefix)
+	}
+	return rules, nil
+}
+
+// Allowed implements IPRules. Deny ranges take precedence over allow ranges.
+func (r *StaticIPRules) Allowed(ip netip.Addr) bool {
+	for _, prefix := range r.deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(r.allow) == 0 {
+		return true
+	}
+	for _, prefix := range r.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIPRules parses one rule per line, "allow <range>" or "deny <range>".
+// Blank lines and lines starting with # are ignored.
+func ParseIPRules(data []byte) (*StaticIPRules, error) {
+	var allow, deny []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"allow|deny <range>\"", n)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", n, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewStaticIPRules(allow, deny)
+}
+
+// IPRulesFile loads rules from a file in the ParseIPRules format. The file is
+// re-read when it changes, checked at most once per refresh interval.
+type IPRulesFile struct {
+	path    string
+	refresh time.Duration
+
+	mu        sync.Mutex
+	rules     *StaticIPRules
+	modTime   time.Time
+	checkedAt time.Time
+	now       func() time.Time
+}
+
+// NewIPRulesFile loads a rules file, checking it for changes every refresh interval
+func NewIPRulesFile(path string, refresh time.Duration) (*IPRulesFile, error) {
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+	f := &IPRulesFile{path: path, refresh: refresh, now: time.Now}
+	if err := f.reload(true); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Allowed implements IPRules
+func (f *IPRulesFile) Allowed(ip netip.Addr) bool {
+	f.mu.Lock()
+	if f.now().Sub(f.checkedAt) >= f.refresh {
+		// Keep enforcing the previous rules if the file is briefly unreadable or invalid
+		_ = f.reload(false)
+	}
+	rules := f.rules
+	f.mu.Unlock()
+
+	return rules.Allowed(ip)
+}
+
+// Reload re-reads the file immediately, e.g. from a SIGHUP handler
+func (f *IPRulesFile) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reload(true)
+}
+
+// reload reads the file if it changed since the last load
+func (f *IPRulesFile) reload(force bool) error {
+	f.checkedAt = f.now()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if !force && info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	rules, err := ParseIPRules(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	f.rules = rules
+	f.modTime = info.ModTime()
+	return nil
+}
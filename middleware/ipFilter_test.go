@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func ipFilterStatus(router *fuselage.Router, remoteAddr string, headers map[string]string) int {
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func newIPFilterRouter(mw fuselage.MiddlewareFunc) *fuselage.Router {
+	router := fuselage.New()
+	router.Use(mw)
+	router.GET("/admin", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+	return router
+}
+
+func TestIPFilter(t *testing.T) {
+	router := newIPFilterRouter(IPFilterWithConfig(IPFilterConfig{
+		Allow: []string{"10.8.0.0/16", "2001:db8:1::/48"},
+		Deny:  []string{"10.8.66.0/24"},
+	}))
+
+	tests := []struct {
+		remoteAddr string
+		expected   int
+	}{
+		{"10.8.1.20:5000", http.StatusOK},
+		{"10.8.66.7:5000", http.StatusForbidden},
+		{"203.0.113.9:5000", http.StatusForbidden},
+		{"[2001:db8:1::42]:443", http.StatusOK},
+		{"[2001:db8:2::42]:443", http.StatusForbidden},
+		{"[::ffff:10.8.1.20]:5000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := ipFilterStatus(router, tt.remoteAddr, nil); code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.remoteAddr, tt.expected, code)
+		}
+	}
+}
+
+func TestIPFilterDenyOnly(t *testing.T) {
+	router := newIPFilterRouter(IPFilterWithConfig(IPFilterConfig{Deny: []string{"203.0.113.9"}}))
+
+	if code := ipFilterStatus(router, "203.0.113.9:1", nil); code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", code)
+	}
+	if code := ipFilterStatus(router, "203.0.113.10:1", nil); code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+}
+
+func TestIPFilterTrustedProxy(t *testing.T) {
+	router := newIPFilterRouter(IPFilter("10.8.0.0/16"))
+	forwarded := map[string]string{"X-Forwarded-For": "10.8.1.20"}
+
+	// Forwarding headers are ignored until the proxy is trusted
+	if code := ipFilterStatus(router, "172.16.0.1:80", forwarded); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 from untrusted proxy, got %d", code)
+	}
+
+	extractor, _ := fuselage.NewIPExtractor("172.16.0.0/12")
+	router.SetIPExtractor(extractor)
+	if code := ipFilterStatus(router, "172.16.0.1:80", forwarded); code != http.StatusOK {
+		t.Errorf("Expected status 200 via trusted proxy, got %d", code)
+	}
+	spoofed := map[string]string{"X-Forwarded-For": "10.8.1.20, 198.51.100.4"}
+	if code := ipFilterStatus(router, "172.16.0.1:80", spoofed); code != http.StatusForbidden {
+		t.Errorf("Expected spoofed entry to be ignored, got %d", code)
+	}
+}
+
+func TestIPFilterErrorHandler(t *testing.T) {
+	router := newIPFilterRouter(IPFilterWithConfig(IPFilterConfig{
+		Allow: []string{"10.0.0.0/8"},
+		ErrorHandler: func(c *fuselage.Context, err error) error {
+			return c.String(http.StatusNotFound, "Not Found")
+		},
+	}))
+
+	if code := ipFilterStatus(router, "203.0.113.9:1", nil); code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", code)
+	}
+}
+
+func TestIPFilterInvalidRangePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for invalid range")
+		}
+	}()
+	IPFilter("10.0.0.0/40")
+}
+
+func TestIPRulesFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.rules")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("# office VPN\nallow 10.8.0.0/16\n", start)
+
+	rules, err := NewIPRulesFile(path, time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock := newFakeClock()
+	rules.now = clock.Now
+	rules.checkedAt = clock.Now()
+
+	vpn := netip.MustParseAddr("10.8.3.4")
+	home := netip.MustParseAddr("198.51.100.4")
+	if !rules.Allowed(vpn) || rules.Allowed(home) {
+		t.Fatalf("Unexpected initial rules")
+	}
+
+	write("allow 10.8.0.0/16\nallow 198.51.100.0/24\ndeny 10.8.3.4\n", start.Add(time.Minute))
+	if rules.Allowed(home) {
+		t.Errorf("Expected rules to be cached until the refresh interval")
+	}
+
+	clock.Advance(time.Minute)
+	if !rules.Allowed(home) || rules.Allowed(vpn) {
+		t.Errorf("Expected updated rules after refresh")
+	}
+
+	// Invalid files keep the previous rules in force
+	write("permit everything\n", start.Add(2*time.Minute))
+	clock.Advance(time.Minute)
+	if !rules.Allowed(home) {
+		t.Errorf("Expected previous rules to stay in force")
+	}
+	if err := rules.Reload(); err == nil {
+		t.Errorf("Expected Reload to report the invalid file")
+	}
+}
+
+func TestParseIPRules(t *testing.T) {
+	if _, err := ParseIPRules([]byte("allow 10.0.0.0/8\n\n# comment\ndeny ::1\n")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, bad := range []string{"allow", "block 10.0.0.1", "allow 300.0.0.1"} {
+		if _, err := ParseIPRules([]byte(bad)); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// JWTKey is a key for verifying token signatures
+type JWTKey struct {
+	// ID matches the token's kid header (optional when a set has one key per algorithm)
+	ID string
+	// Algorithm is one of HS256, RS256, ES256 or EdDSA
+	Algorithm string
+	// Key is a []byte secret for HS256, *rsa.PublicKey for RS256,
+	// *ecdsa.PublicKey for ES256 or ed25519.PublicKey for EdDSA
+	Key interface{}
+}
+
+// JWTKeySet looks up verification keys
+type JWTKeySet interface {
+	// Key returns the key for a token's kid and alg headers
+	Key(kid, alg string) (*JWTKey, error)
+}
+
+// StaticKeySet is a fixed set of verification keys
+type StaticKeySet []JWTKey
+
+// NewStaticKeySet creates a key set from keys
+func NewStaticKeySet(keys ...JWTKey) StaticKeySet {
+	return StaticKeySet(keys)
+}
+
+// Key implements JWTKeySet
+func (s StaticKeySet) Key(kid, alg string) (*JWTKey, error) {
+	return findKey(s, kid, alg)
+}
+
+// findKey matches kid when given, otherwise the only key for alg
+func findKey(keys []JWTKey, kid, alg string) (*JWTKey, error) {
+	var match *JWTKey
+	for i := range keys {
+		key := &keys[i]
+		if key.Algorithm != alg {
+			continue
+		}
+		if kid != "" {
+			if key.ID == kid {
+				return key, nil
+			}
+			continue
+		}
+		if match != nil {
+			return nil, ErrJWTUnknownKey
+		}
+		match = key
+	}
+	if match == nil {
+		return nil, ErrJWTUnknownKey
+	}
+	return match, nil
+}
+
+// JWKSFileKeySet loads keys from a local JSON Web Key Set file. The file is
+// re-read when it changes, checked at most once per refresh interval, and
+// also when a token names an unknown kid.
+type JWKSFileKeySet struct {
+	path    string
+	refresh time.Duration
+
+	mu        sync.Mutex
+	keys      []JWTKey
+	modTime   time.Time
+	checkedAt time.Time
+	now       func() time.Time
+}
+
+// NewJWKSFileKeySet loads a JWKS file, checking it for changes every refresh interval
+func NewJWKSFileKeySet(path string, refresh time.Duration) (*JWKSFileKeySet, error) {
+	if refresh <= 0 {
+		refresh = 5 * time.Minute
+	}
+	s := &JWKSFileKeySet{path: path, refresh: refresh, now: time.Now}
+	if err := s.reload(true); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Key implements JWTKeySet
+func (s *JWKSFileKeySet) Key(kid, alg string) (*JWTKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.now().Sub(s.checkedAt) >= s.refresh {
+		// Keep serving the previous keys if the file is briefly unreadable
+		_ = s.reload(false)
+	}
+	key, err := findKey(s.keys, kid, alg)
+	if err != nil && kid != "" && s.now().Sub(s.checkedAt) >= time.Second {
+		// An unknown kid may mean the keys were just rotated
+		if s.reload(false) == nil {
+			key, err = findKey(s.keys, kid, alg)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// reload reads the file if it changed since the last load
+func (s *JWKSFileKeySet) reload(force bool) error {
+	s.checkedAt = s.now()
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !force && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.modTime = info.ModTime()
+	return nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. Keys not meant for signatures or
+// using unsupported types are skipped.
+func ParseJWKS(data []byte) ([]JWTKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make([]JWTKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys = append(keys, *key)
+		}
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) parse() (*JWTKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("unsupported RSA key")
+		}
+		return jwkKey(jwk, "RS256", &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())})
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 coordinates")
+		}
+		// Validates that the point is on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return jwkKey(jwk, "ES256", &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		})
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return jwkKey(jwk, "EdDSA", ed25519.PublicKey(x))
+	case "oct":
+		k, err := decode(jwk.K)
+		if err != nil {
+			return nil, err
+		}
+		return jwkKey(jwk, "HS256", k)
+	}
+	return nil, nil
+}
+
+func jwkKey(jwk jsonWebKey, defaultAlg string, key interface{}) (*JWTKey, error) {
+	alg := jwk.Alg
+	if alg == "" {
+		alg = defaultAlg
+	}
+	if alg != defaultAlg {
+		// The algorithm must fit the key type
+		return nil, nil
+	}
+	return &JWTKey{ID: jwk.Kid, Algorithm: alg, Key: key}, nil
+}
@@ -0,0 +1,355 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+var (
+	ErrJWTMissing     = errors.New("missing or malformed JWT")
+	ErrJWTInvalid     = errors.New("invalid JWT")
+	ErrJWTUnknownKey  = errors.New("JWT signing key not found")
+	ErrJWTExpired     = errors.New("JWT has expired")
+	ErrJWTNotYetValid = errors.New("JWT is not valid yet")
+	ErrJWTIssuer      = errors.New("JWT issuer not accepted")
+	ErrJWTAudience    = errors.New("JWT audience not accepted")
+)
+
+// JWTClaims holds the registered claims of a verified token
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+	// Raw holds every claim in the payload
+	Raw map[string]interface{}
+
+	payload []byte
+}
+
+// Decode unmarshals the token payload into a custom claims struct
+func (c *JWTClaims) Decode(v interface{}) error {
+	return json.Unmarshal(c.payload, v)
+}
+
+// Scopes returns the space-separated "scope" claim or the "scp" array
+func (c *JWTClaims) Scopes() []string {
+	if scope, ok := c.Raw["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return stringList(c.Raw["scp"])
+}
+
+// Roles returns the "roles" claim
+func (c *JWTClaims) Roles() []string {
+	return stringList(c.Raw["roles"])
+}
+
+type JWTConfig struct {
+	// Keys verifies token signatures (required)
+	Keys JWTKeySet
+	// Algorithms lists accepted signature algorithms
+	Algorithms []string
+	// Issuer is the required iss claim (empty accepts any)
+	Issuer string
+	// Audience lists accepted aud values; tokens must name one of them (empty accepts any)
+	Audience []string
+	// ClockSkew is the leeway for exp, nbf and iat checks (negative disables leeway)
+	ClockSkew time.Duration
+	// TokenLookup defines where tokens are read from, e.g. "header:Authorization,query:access_token".
+	// A "Bearer " prefix is removed.
+	TokenLookup string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles missing or rejected tokens
+	ErrorHandler func(*fuselage.Context, error) error
+
+	now func() time.Time
+}
+
+var DefaultJWTConfig = JWTConfig{
+	Algorithms:  []string{"HS256", "RS256", "ES256", "EdDSA"},
+	ClockSkew:   time.Minute,
+	TokenLookup: "header:" + fuselage.HeaderAuthorization,
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		challenge := `Bearer error="invalid_token"`
+		if errors.Is(err, ErrJWTMissing) {
+			challenge = "Bearer"
+		}
+		c.SetHeader(fuselage.HeaderWWWAuthenticate, challenge)
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+// JWT authenticates bearer tokens verified with keys
+func JWT(keys JWTKeySet) fuselage.MiddlewareFunc {
+	config := DefaultJWTConfig
+	config.Keys = keys
+	return JWTWithConfig(config)
+}
+
+// JWTWithConfig authenticates compact JWS bearer tokens. Verified claims are
+// available through GetJWTClaims, and a Principal is stored with the subject,
+// scopes and roles. It panics if Keys is nil.
+func JWTWithConfig(config JWTConfig) fuselage.MiddlewareFunc {
+	if config.Keys == nil {
+		panic("fuselage: JWT middleware requires a key set")
+	}
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = DefaultJWTConfig.Algorithms
+	}
+	if config.ClockSkew == 0 {
+		config.ClockSkew = DefaultJWTConfig.ClockSkew
+	} else if config.ClockSkew < 0 {
+		config.ClockSkew = 0
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultJWTConfig.TokenLookup
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultJWTConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultJWTConfig.ErrorHandler
+	}
+	if config.now == nil {
+		config.now = time.Now
+	}
+
+	extractors := mustCreateExtractors(config.TokenLookup)
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			token := extractValue(c, extractors)
+			if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+				token = strings.TrimSpace(token[7:])
+			}
+			if token == "" {
+				return config.ErrorHandler(c, ErrJWTMissing)
+			}
+
+			claims, err := ParseJWT(token, &config)
+			if err != nil {
+				return config.ErrorHandler(c, err)
+			}
+
+			ctx := context.WithValue(c.Request.Context(), fuselage.JWTClaimsKey, claims)
+			c.Request = c.Request.WithContext(ctx)
+			SetPrincipal(c, &Principal{
+				ID:       claims.Subject,
+				Scheme:   "bearer",
+				Roles:    claims.Roles(),
+				Scopes:   claims.Scopes(),
+				Metadata: claims.Raw,
+			})
+			return next(c)
+		}
+	}
+}
+
+// GetJWTClaims returns the verified claims of the request's token
+func GetJWTClaims(c *fuselage.Context) *JWTClaims {
+	if claims, ok := c.Request.Context().Value(fuselage.JWTClaimsKey).(*JWTClaims); ok {
+		return claims
+	}
+	return nil
+}
+
+// ParseJWT verifies a compact JWS token and validates its registered claims
+func ParseJWT(token string, config *JWTConfig) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMissing
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Typ string `json:"typ"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrJWTInvalid
+	}
+	if !containsString(config.Algorithms, header.Alg) {
+		return nil, ErrJWTInvalid
+	}
+
+	key, err := config.Keys.Key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, ErrJWTUnknownKey
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTInvalid
+	}
+	if !verifyJWTSignature(header.Alg, key.Key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrJWTInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrJWTInvalid
+	}
+	claims, err := parseJWTClaims(payload)
+	if err != nil {
+		return nil, ErrJWTInvalid
+	}
+
+	now := time.Now()
+	if config.now != nil {
+		now = config.now()
+	}
+	skew := config.ClockSkew
+	if !claims.ExpiresAt.IsZero() && !now.Before(claims.ExpiresAt.Add(skew)) {
+		return nil, ErrJWTExpired
+	}
+	if !claims.NotBefore.IsZero() && now.Add(skew).Before(claims.NotBefore) {
+		return nil, ErrJWTNotYetValid
+	}
+	if !claims.IssuedAt.IsZero() && now.Add(skew).Before(claims.IssuedAt) {
+		return nil, ErrJWTNotYetValid
+	}
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return nil, ErrJWTIssuer
+	}
+	if len(config.Audience) > 0 && !audienceAccepted(claims.Audience, config.Audience) {
+		return nil, ErrJWTAudience
+	}
+	return claims, nil
+}
+
+func verifyJWTSignature(alg string, key interface{}, signingInput, signature []byte) bool {
+	digest := sha256.Sum256(signingInput)
+
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().Name != "P-256" || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && len(pub) == ed25519.PublicKeySize && ed25519.Verify(pub, signingInput, signature)
+	}
+	return false
+}
+
+// maxJWTNumericDate bounds exp, nbf and iat (the end of year 9999) so the
+// conversion to int64 cannot overflow. The negated comparison also rejects NaN.
+const maxJWTNumericDate = 253402300799
+
+func parseJWTClaims(payload []byte) (*JWTClaims, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+
+	claims := &JWTClaims{Raw: raw, payload: payload}
+	var ok bool
+	for name, target := range map[string]*string{"iss": &claims.Issuer, "sub": &claims.Subject, "jti": &claims.ID} {
+		if v, present := raw[name]; present {
+			if *target, ok = v.(string); !ok {
+				return nil, ErrJWTInvalid
+			}
+		}
+	}
+	for name, target := range map[string]*time.Time{"exp": &claims.ExpiresAt, "nbf": &claims.NotBefore, "iat": &claims.IssuedAt} {
+		if v, present := raw[name]; present {
+			seconds, ok := v.(float64)
+			if !ok || !(seconds >= -maxJWTNumericDate && seconds <= maxJWTNumericDate) {
+				return nil, ErrJWTInvalid
+			}
+			*target = time.Unix(int64(seconds), 0)
+		}
+	}
+	switch aud := raw["aud"].(type) {
+	case nil:
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		claims.Audience = stringList(aud)
+	default:
+		return nil, ErrJWTInvalid
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func audienceAccepted(audience, accepted []string) bool {
+	for _, aud := range audience {
+		if containsString(accepted, aud) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func stringList(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
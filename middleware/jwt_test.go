@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func signTestJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	p, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case "EdDSA":
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(input))
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func serveJWT(router *fuselage.Router, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/me", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	keys := NewStaticKeySet(
+		JWTKey{ID: "hs", Algorithm: "HS256", Key: secret},
+		JWTKey{ID: "rs", Algorithm: "RS256", Key: &rsaKey.PublicKey},
+		JWTKey{ID: "es", Algorithm: "ES256", Key: &ecKey.PublicKey},
+		JWTKey{ID: "ed", Algorithm: "EdDSA", Key: edPub},
+	)
+
+	router := fuselage.New()
+	router.Use(JWT(keys))
+	router.GET("/me", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetJWTClaims(c).Subject)
+	})
+
+	claims := map[string]interface{}{"sub": "svc-billing", "exp": time.Now().Add(time.Hour).Unix()}
+	for alg, signer := range map[string]struct {
+		kid string
+		key interface{}
+	}{
+		"HS256": {"hs", secret},
+		"RS256": {"rs", rsaKey},
+		"ES256": {"es", ecKey},
+		"EdDSA": {"ed", edKey},
+	} {
+		rec := serveJWT(router, signTestJWT(t, alg, signer.kid, signer.key, claims))
+		if rec.Code != http.StatusOK || rec.Body.String() != "svc-billing" {
+			t.Errorf("%s: expected verified token, got %d '%s'", alg, rec.Code, rec.Body.String())
+		}
+	}
+
+	// A token signed by a different key or claiming another key's algorithm is rejected
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for _, token := range []string{
+		signTestJWT(t, "ES256", "es", otherKey, claims),
+		signTestJWT(t, "HS256", "rs", secret, claims),
+		"not.a.jwt",
+		"",
+	} {
+		rec := serveJWT(router, token)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", rec.Code)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected bearer challenge")
+		}
+	}
+}
+
+func TestJWTClaimsValidation(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	router := fuselage.New()
+	router.Use(JWTWithConfig(JWTConfig{
+		Keys:      NewStaticKeySet(JWTKey{Algorithm: "HS256", Key: secret}),
+		Issuer:    "https://auth.example.com",
+		Audience:  []string{"orders"},
+		ClockSkew: 30 * time.Second,
+		now:       func() time.Time { return now },
+	}))
+	router.GET("/me", func(c *fuselage.Context) error {
+		var custom struct {
+			Tenant string `json:"tenant"`
+		}
+		if err := GetJWTClaims(c).Decode(&custom); err != nil {
+			return err
+		}
+		p := GetPrincipal(c)
+		return c.String(http.StatusOK, custom.Tenant+":"+p.ID+":"+p.Scopes[1])
+	})
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    "https://auth.example.com",
+			"aud":    []string{"inventory", "orders"},
+			"sub":    "user-1",
+			"exp":    now.Add(time.Minute).Unix(),
+			"nbf":    now.Unix(),
+			"iat":    now.Unix(),
+			"scope":  "orders:read orders:write",
+			"tenant": "acme",
+		}
+	}
+
+	rec := serveJWT(router, signTestJWT(t, "HS256", "", secret, valid()))
+	if rec.Code != http.StatusOK || rec.Body.String() != "acme:user-1:orders:write" {
+		t.Fatalf("Expected valid token, got %d '%s'", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		modify func(map[string]interface{})
+		ok     bool
+	}{
+		{"expired", func(c map[string]interface{}) { c["exp"] = now.Add(-time.Minute).Unix() }, false},
+		{"expired within skew", func(c map[string]interface{}) { c["exp"] = now.Add(-10 * time.Second).Unix() }, true},
+		{"not yet valid", func(c map[string]interface{}) { c["nbf"] = now.Add(time.Minute).Unix() }, false},
+		{"issued in the future", func(c map[string]interface{}) { c["iat"] = now.Add(time.Hour).Unix() }, false},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, false},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "payments" }, false},
+		{"malformed exp", func(c map[string]interface{}) { c["exp"] = "tomorrow" }, false},
+		{"out of range exp", func(c map[string]interface{}) { c["exp"] = 1e19 }, false},
+		{"out of range nbf", func(c map[string]interface{}) { c["nbf"] = -1e19 }, false},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		tt.modify(claims)
+		rec := serveJWT(router, signTestJWT(t, "HS256", "", secret, claims))
+		if (rec.Code == http.StatusOK) != tt.ok {
+			t.Errorf("%s: unexpected status %d", tt.name, rec.Code)
+		}
+	}
+}
+
+func TestJWTZeroClockSkew(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	router := fuselage.New()
+	router.Use(JWTWithConfig(JWTConfig{
+		Keys:      NewStaticKeySet(JWTKey{Algorithm: "HS256", Key: secret}),
+		ClockSkew: -1,
+		now:       func() time.Time { return now },
+	}))
+	router.GET("/me", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	token := signTestJWT(t, "HS256", "", secret, map[string]interface{}{"exp": now.Add(-time.Second).Unix()})
+	if rec := serveJWT(router, token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected token expired a second ago to be rejected without leeway, got %d", rec.Code)
+	}
+	token = signTestJWT(t, "HS256", "", secret, map[string]interface{}{"exp": now.Add(time.Second).Unix()})
+	if rec := serveJWT(router, token); rec.Code != http.StatusOK {
+		t.Errorf("Expected unexpired token to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestJWKSFileKeySet(t *testing.T) {
+	key1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwk := func(kid string, key *ecdsa.PrivateKey) map[string]string {
+		return map[string]string{
+			"kty": "EC", "crv": "P-256", "kid": kid, "use": "sig",
+			"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	write := func(keys ...map[string]string) {
+		data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(jwk("k1", key1), map[string]string{"kty": "RSA", "kid": "enc", "use": "enc"})
+	keys, err := NewJWKSFileKeySet(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := fuselage.New()
+	router.Use(JWT(keys))
+	router.GET("/me", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetPrincipal(c).ID)
+	})
+
+	claims := map[string]interface{}{"sub": "svc"}
+	if rec := serveJWT(router, signTestJWT(t, "ES256", "k1", key1, claims)); rec.Code != http.StatusOK {
+		t.Fatalf("Expected key from JWKS file, got %d", rec.Code)
+	}
+
+	// Rotate keys; an unknown kid triggers a reload before the refresh interval
+	write(jwk("k2", key2))
+	future := time.Now().Add(2 * time.Second)
+	_ = os.Chtimes(path, future, future)
+	keys.now = func() time.Time { return time.Now().Add(2 * time.Second) }
+
+	if rec := serveJWT(router, signTestJWT(t, "ES256", "k2", key2, claims)); rec.Code != http.StatusOK {
+		t.Errorf("Expected rotated key to be loaded, got %d", rec.Code)
+	}
+	if rec := serveJWT(router, signTestJWT(t, "ES256", "k1", key1, claims)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected removed key to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestParseJWKSRejectsInvalidKeys(t *testing.T) {
+	invalid := `{"keys":[{"kty":"EC","crv":"P-256","kid":"bad","x":"AAAA","y":"AAAA"}]}`
+	if _, err := ParseJWKS([]byte(invalid)); err == nil {
+		t.Error("Expected invalid EC key to be rejected")
+	}
+}
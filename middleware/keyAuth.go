@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+var (
+	ErrAPIKeyMissing = errors.New("missing API key")
+	ErrAPIKeyInvalid = errors.New("invalid API key")
+)
+
+// APIKey describes the holder of a valid API key
+type APIKey struct {
+	// Owner identifies the partner or service the key was issued to
+	Owner string
+	// Scopes granted to the key
+	Scopes []string
+	// Metadata holds additional per-key details
+	Metadata map[string]interface{}
+}
+
+// KeyAuthValidator resolves a key, returning nil for unknown keys.
+// Returning an error responds with 500.
+type KeyAuthValidator func(key string, c *fuselage.Context) (*APIKey, error)
+
+type KeyAuthConfig struct {
+	// KeyLookup defines where keys are read from, e.g. "header:X-API-Key,query:api_key,cookie:api_key"
+	KeyLookup string
+	// Validator resolves keys (required)
+	Validator KeyAuthValidator
+	// Realm is sent in the WWW-Authenticate challenge
+	Realm string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles missing or invalid keys after the challenge is set
+	ErrorHandler func(*fuselage.Context, error) error
+}
+
+var DefaultKeyAuthConfig = KeyAuthConfig{
+	KeyLookup: "header:X-API-Key",
+	Realm:     "API",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	},
+}
+
+// KeyAuth authenticates requests with an API key in the X-API-Key header
+func KeyAuth(validator KeyAuthValidator) fuselage.MiddlewareFunc {
+	config := DefaultKeyAuthConfig
+	config.Validator = validator
+	return KeyAuthWithConfig(config)
+}
+
+// KeyAuthWithConfig authenticates requests with API keys. The resolved key is
+// available through GetAPIKey, and a Principal is stored with the owner and
+// scopes. It panics if Validator is nil or KeyLookup is invalid.
+func KeyAuthWithConfig(config KeyAuthConfig) fuselage.MiddlewareFunc {
+	if config.Validator == nil {
+		panic("fuselage: key auth middleware requires a validator")
+	}
+	if config.KeyLookup == "" {
+		config.KeyLookup = DefaultKeyAuthConfig.KeyLookup
+	}
+	if config.Realm == "" {
+		config.Realm = DefaultKeyAuthConfig.Realm
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultKeyAuthConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultKeyAuthConfig.ErrorHandler
+	}
+
+	extractors := mustCreateExtractors(config.KeyLookup)
+	challenge := `APIKey realm="` + quoteEscaper.Replace(config.Realm) + `"`
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			key := extractValue(c, extractors)
+			if key == "" {
+				c.SetHeader(fuselage.HeaderWWWAuthenticate, challenge)
+				return config.ErrorHandler(c, ErrAPIKeyMissing)
+			}
+
+			apiKey, err := config.Validator(key, c)
+			if err != nil {
+				return err
+			}
+			if apiKey == nil {
+				c.SetHeader(fuselage.HeaderWWWAuthenticate, challenge+`, error="invalid_key"`)
+				return config.ErrorHandler(c, ErrAPIKeyInvalid)
+			}
+
+			ctx := context.WithValue(c.Request.Context(), fuselage.APIKeyKey, apiKey)
+			c.Request = c.Request.WithContext(ctx)
+			SetPrincipal(c, &Principal{
+				ID:       apiKey.Owner,
+				Scheme:   "apikey",
+				Scopes:   apiKey.Scopes,
+				Metadata: apiKey.Metadata,
+			})
+			return next(c)
+		}
+	}
+}
+
+// GetAPIKey returns the API key that authenticated the request
+func GetAPIKey(c *fuselage.Context) *APIKey {
+	if key, ok := c.Request.Context().Value(fuselage.APIKeyKey).(*APIKey); ok {
+		return key
+	}
+	return nil
+}
+
+// HashAPIKey returns the hex SHA-256 digest under which a key should be stored
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashedKeyValidator resolves keys against a map from HashAPIKey digests to
+// key details, so plaintext keys never need to be kept in memory or config
+func HashedKeyValidator(keys map[string]*APIKey) KeyAuthValidator {
+	return func(key string, c *fuselage.Context) (*APIKey, error) {
+		return keys[HashAPIKey(key)], nil
+	}
+}
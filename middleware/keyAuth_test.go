@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func TestKeyAuth(t *testing.T) {
+	keys := map[string]*APIKey{
+		HashAPIKey("pk_live_acme"): {
+			Owner:    "acme",
+			Scopes:   []string{"orders:read"},
+			Metadata: map[string]interface{}{"tier": "gold"},
+		},
+	}
+
+	router := fuselage.New()
+	router.Use(KeyAuthWithConfig(KeyAuthConfig{
+		KeyLookup: "header:X-API-Key,query:api_key,cookie:api_key",
+		Validator: HashedKeyValidator(keys),
+	}))
+	router.GET("/orders", func(c *fuselage.Context) error {
+		key := GetAPIKey(c)
+		p := GetPrincipal(c)
+		return c.String(http.StatusOK, key.Owner+":"+key.Metadata["tier"].(string)+":"+p.Scheme+":"+strings.Join(p.Scopes, ","))
+	})
+
+	requests := []*http.Request{
+		httptest.NewRequest("GET", "/orders", nil),
+		httptest.NewRequest("GET", "/orders?api_key=pk_live_acme", nil),
+		httptest.NewRequest("GET", "/orders", nil),
+	}
+	requests[0].Header.Set("X-API-Key", "pk_live_acme")
+	requests[2].AddCookie(&http.Cookie{Name: "api_key", Value: "pk_live_acme"})
+
+	for _, req := range requests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.String() != "acme:gold:apikey:orders:read" {
+			t.Errorf("Expected authenticated key, got %d '%s'", rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/orders", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != `APIKey realm="API"` {
+		t.Errorf("Expected 401 challenge, got %d '%s'", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	req = httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set("X-API-Key", "pk_live_unknown")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), `error="invalid_key"`) {
+		t.Errorf("Expected invalid key challenge, got %d '%s'", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	if !strings.Contains(rec.Body.String(), ErrAPIKeyInvalid.Error()) {
+		t.Errorf("Expected error body, got '%s'", rec.Body.String())
+	}
+}
+
+func TestKeyAuthValidatorError(t *testing.T) {
+	router := fuselage.New()
+	router.Use(KeyAuth(func(key string, c *fuselage.Context) (*APIKey, error) {
+		return nil, errors.New("key store unavailable")
+	}))
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-API-Key", "anything")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	if HashAPIKey("key") != HashAPIKey("key") || HashAPIKey("key") == HashAPIKey("key2") {
+		t.Error("Expected deterministic, distinct digests")
+	}
+	if len(HashAPIKey("key")) != 64 {
+		t.Errorf("Expected hex SHA-256 digest, got %d characters", len(HashAPIKey("key")))
+	}
+}
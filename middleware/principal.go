@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	// ID is the user name, token subject or key owner
+	ID string
+	// Scheme is the authentication method, e.g. "basic", "bearer" or "apikey"
+	Scheme string
+	// Roles granted to the principal
+	Roles []string
+	// Scopes granted to the principal
+	Scopes []string
+	// Metadata holds scheme-specific details
+	Metadata map[string]interface{}
+}
+
+// GetPrincipal returns the authenticated principal, or nil for anonymous requests
+func GetPrincipal(c *fuselage.Context) *Principal {
+	if p, ok := c.Request.Context().Value(fuselage.PrincipalKey).(*Principal); ok {
+		return p
+	}
+	return nil
+}
+
+// SetPrincipal stores the authenticated principal for downstream handlers.
+// Validators may call it to attach roles or metadata.
+func SetPrincipal(c *fuselage.Context, p *Principal) {
+	ctx := context.WithValue(c.Request.Context(), fuselage.PrincipalKey, p)
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// SecureCompare reports whether a and b are equal in constant time,
+// without leaking their lengths
+func SecureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
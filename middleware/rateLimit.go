@@ -1,49 +1,67 @@
 package middleware
 
 import (
-	"net"
+	"io"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/k-tsurumaki/fuselage"
 )
 
+// RateLimitHeaders selects which rate limit headers are sent
+type RateLimitHeaders int
+
+const (
+	// RateLimitHeadersIETF sends RateLimit-Limit, RateLimit-Remaining and
+	// RateLimit-Reset (seconds until reset) as in the IETF draft
+	RateLimitHeadersIETF RateLimitHeaders = iota
+	// RateLimitHeadersLegacy sends X-RateLimit-Limit, X-RateLimit-Remaining and
+	// X-RateLimit-Reset (Unix time of reset)
+	RateLimitHeadersLegacy
+	// RateLimitHeadersNone sends no rate limit headers; Retry-After is still sent on 429
+	RateLimitHeadersNone
+)
+
 type RateLimitConfig struct {
 	// Requests per window
 	Limit int
 	// Time window duration
 	Window time.Duration
-	// Key generator function (default: IP-based)
+	// Algorithm used to count requests (default: FixedWindow)
+	Algorithm RateLimitAlgorithm
+	// Burst is the maximum burst size for TokenBucket and GCRA (default: Limit)
+	Burst int
+	// Store counts requests; Limit, Window, Algorithm and Burst only configure
+	// the default in-memory store (default: NewMemoryRateLimitStore).
+	// Stores implementing io.Closer are closed only by RateLimiter.Close.
+	Store RateLimitStore
+	// Headers selects the rate limit headers added to every response
+	Headers RateLimitHeaders
+	// Key generator function (default: Context.RealIP)
 	KeyGenerator func(*fuselage.Context) string
 	// Skip function to bypass rate limiting
 	Skipper func(*fuselage.Context) bool
-	// Error handler for rate limit exceeded
-	ErrorHandler func(*fuselage.Context) error
-}
+	// Error handler for rate limit exceeded, called with the limiter state
+	// after the rate limit headers and Retry-After have been set
+	ErrorHandler func(*fuselage.Context, RateLimitResult) error
 
-type rateLimiter struct {
-	requests map[string]*bucket
-	mutex    sync.RWMutex
-	config   RateLimitConfig
-}
-
-type bucket struct {
-	count     int
-	resetTime time.Time
+	// now returns the current time (overridden in tests)
+	now func() time.Time
 }
 
 var DefaultRateLimitConfig = RateLimitConfig{
-	Limit:  100,
-	Window: time.Minute,
+	Limit:     100,
+	Window:    time.Minute,
+	Algorithm: FixedWindow,
 	KeyGenerator: func(c *fuselage.Context) string {
-		ip, _, _ := net.SplitHostPort(c.Request.RemoteAddr)
-		return ip
+		return c.RealIP()
 	},
 	Skipper: func(c *fuselage.Context) bool {
 		return false
 	},
-	ErrorHandler: func(c *fuselage.Context) error {
+	ErrorHandler: func(c *fuselage.Context, result RateLimitResult) error {
 		return c.JSON(http.StatusTooManyRequests, map[string]string{
 			"error": "Rate limit exceeded",
 		})
@@ -54,7 +72,21 @@ func RateLimit() fuselage.MiddlewareFunc {
 	return RateLimitWithConfig(DefaultRateLimitConfig)
 }
 
+// RateLimitWithConfig returns rate limiting middleware. The caller owns
+// config.Store; add a RateLimiter with Router.UseCloser to have it closed
+// with the router.
 func RateLimitWithConfig(config RateLimitConfig) fuselage.MiddlewareFunc {
+	return NewRateLimiter(config).Middleware()
+}
+
+// RateLimiter is rate limiting middleware that can be closed with its store,
+// e.g. by adding it with Router.UseCloser
+type RateLimiter struct {
+	config RateLimitConfig
+}
+
+// NewRateLimiter creates a rate limiter, filling unset fields from DefaultRateLimitConfig
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
 	if config.Limit <= 0 {
 		config.Limit = DefaultRateLimitConfig.Limit
 	}
@@ -70,14 +102,19 @@ func RateLimitWithConfig(config RateLimitConfig) fuselage.MiddlewareFunc {
 	if config.ErrorHandler == nil {
 		config.ErrorHandler = DefaultRateLimitConfig.ErrorHandler
 	}
-
-	limiter := &rateLimiter{
-		requests: make(map[string]*bucket),
-		config:   config,
+	if config.now == nil {
+		config.now = time.Now
 	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore(config)
+	}
+
+	return &RateLimiter{config: config}
+}
 
-	// Cleanup goroutine
-	go limiter.cleanup()
+// Middleware returns the middleware enforcing the limit
+func (l *RateLimiter) Middleware() fuselage.MiddlewareFunc {
+	config := l.config
 
 	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
 		return func(c *fuselage.Context) error {
@@ -85,9 +122,16 @@ func RateLimitWithConfig(config RateLimitConfig) fuselage.MiddlewareFunc {
 				return next(c)
 			}
 
-			key := config.KeyGenerator(c)
-			if !limiter.allow(key) {
-				return config.ErrorHandler(c)
+			result, err := config.Store.Take(config.KeyGenerator(c))
+			if err != nil {
+				return err
+			}
+
+			now := config.now()
+			setRateLimitHeaders(c, config.Headers, result, now)
+			if !result.Allowed {
+				c.SetHeader(fuselage.HeaderRetryAfter, strconv.FormatInt(secondsUntil(result.RetryAt, now), 10))
+				return config.ErrorHandler(c, result)
 			}
 
 			return next(c)
@@ -95,41 +139,35 @@ func RateLimitWithConfig(config RateLimitConfig) fuselage.MiddlewareFunc {
 	}
 }
 
-func (rl *rateLimiter) allow(key string) bool {
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
-
-	now := time.Now()
-	b, exists := rl.requests[key]
-
-	if !exists || now.After(b.resetTime) {
-		rl.requests[key] = &bucket{
-			count:     1,
-			resetTime: now.Add(rl.config.Window),
-		}
-		return true
+// Close closes the store if it implements io.Closer
+func (l *RateLimiter) Close() error {
+	if closer, ok := l.config.Store.(io.Closer); ok {
+		return closer.Close()
 	}
+	return nil
+}
 
-	if b.count >= rl.config.Limit {
-		return false
+func setRateLimitHeaders(c *fuselage.Context, headers RateLimitHeaders, result RateLimitResult, now time.Time) {
+	limit := strconv.Itoa(result.Limit)
+	remaining := strconv.Itoa(result.Remaining)
+
+	switch headers {
+	case RateLimitHeadersIETF:
+		c.SetHeader(fuselage.HeaderRateLimitLimit, limit)
+		c.SetHeader(fuselage.HeaderRateLimitRemaining, remaining)
+		c.SetHeader(fuselage.HeaderRateLimitReset, strconv.FormatInt(secondsUntil(result.Reset, now), 10))
+	case RateLimitHeadersLegacy:
+		c.SetHeader(fuselage.HeaderXRateLimitLimit, limit)
+		c.SetHeader(fuselage.HeaderXRateLimitRemaining, remaining)
+		c.SetHeader(fuselage.HeaderXRateLimitReset, strconv.FormatInt(int64(math.Ceil(float64(result.Reset.UnixNano())/1e9)), 10))
 	}
-
-	b.count++
-	return true
 }
 
-func (rl *rateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.config.Window)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mutex.Lock()
-		now := time.Now()
-		for key, bucket := range rl.requests {
-			if now.After(bucket.resetTime) {
-				delete(rl.requests, key)
-			}
-		}
-		rl.mutex.Unlock()
+// secondsUntil rounds the time until t up to whole seconds
+func secondsUntil(t, now time.Time) int64 {
+	d := t.Sub(now)
+	if d <= 0 {
+		return 0
 	}
-}
\ No newline at end of file
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimitAlgorithm selects how requests are counted against Limit per Window
+type RateLimitAlgorithm int
+
+const (
+	// FixedWindow counts requests in consecutive windows. Clients can send up to
+	// 2x Limit across a window boundary.
+	FixedWindow RateLimitAlgorithm = iota
+	// TokenBucket refills Limit tokens per Window up to Burst, allowing short bursts
+	TokenBucket
+	// SlidingWindowLog records every request timestamp for exact limiting
+	SlidingWindowLog
+	// SlidingWindowCounter weights the previous window's count to approximate a
+	// sliding window in constant memory per key
+	SlidingWindowCounter
+	// GCRA (generic cell rate algorithm) spaces requests evenly with Burst tolerance
+	GCRA
+)
+
+// rateLimitResult is the outcome of counting one request
+type rateLimitResult struct {
+	allowed bool
+	// remaining is the number of requests still allowed right now
+	remaining int
+	// reset is when the limit is fully replenished
+	reset time.Time
+	// retryAt is when the next request will be allowed (set when denied)
+	retryAt time.Time
+}
+
+// limitState is the per-key state of an algorithm
+type limitState interface {
+	take(now time.Time) rateLimitResult
+	// expired reports whether the state is equivalent to a fresh one
+	expired(now time.Time) bool
+}
+
+// limitParams are the resolved settings shared by all keys
+type limitParams struct {
+	limit  int
+	window time.Duration
+	burst  int
+}
+
+func newLimitState(algorithm RateLimitAlgorithm, p *limitParams, now time.Time) limitState {
+	switch algorithm {
+	case TokenBucket:
+		return &tokenBucketState{params: p, tokens: float64(p.burst), last: now}
+	case SlidingWindowLog:
+		return &slidingLogState{params: p}
+	case SlidingWindowCounter:
+		return &slidingCounterState{params: p}
+	case GCRA:
+		return &gcraState{params: p}
+	default:
+		return &fixedWindowState{params: p}
+	}
+}
+
+type fixedWindowState struct {
+	params    *limitParams
+	count     int
+	resetTime time.Time
+}
+
+func (s *fixedWindowState) take(now time.Time) rateLimitResult {
+	if s.count == 0 || !now.Before(s.resetTime) {
+		s.count = 0
+		s.resetTime = now.Add(s.params.window)
+	}
+	if s.count >= s.params.limit {
+		return rateLimitResult{reset: s.resetTime, retryAt: s.resetTime}
+	}
+	s.count++
+	return rateLimitResult{allowed: true, remaining: s.params.limit - s.count, reset: s.resetTime}
+}
+
+func (s *fixedWindowState) expired(now time.Time) bool {
+	return !now.Before(s.resetTime)
+}
+
+type tokenBucketState struct {
+	params *limitParams
+	tokens float64
+	last   time.Time
+}
+
+// rate returns tokens added per nanosecond
+func (s *tokenBucketState) rate() float64 {
+	return float64(s.params.limit) / float64(s.params.window)
+}
+
+func (s *tokenBucketState) refill(now time.Time) {
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = math.Min(float64(s.params.burst), s.tokens+float64(elapsed)*s.rate())
+		s.last = now
+	}
+}
+
+func (s *tokenBucketState) take(now time.Time) rateLimitResult {
+	s.refill(now)
+	result := rateLimitResult{}
+	if s.tokens >= 1 {
+		s.tokens--
+		result.allowed = true
+	} else {
+		result.retryAt = now.Add(time.Duration(math.Ceil((1 - s.tokens) / s.rate())))
+	}
+	result.remaining = int(s.tokens)
+	result.reset = now.Add(time.Duration(math.Ceil((float64(s.params.burst) - s.tokens) / s.rate())))
+	return result
+}
+
+func (s *tokenBucketState) expired(now time.Time) bool {
+	s.refill(now)
+	return s.tokens >= float64(s.params.burst)
+}
+
+type slidingLogState struct {
+	params *limitParams
+	log    []time.Time
+}
+
+func (s *slidingLogState) evict(now time.Time) {
+	cutoff := now.Add(-s.params.window)
+	i := 0
+	for i < len(s.log) && !s.log[i].After(cutoff) {
+		i++
+	}
+	s.log = s.log[i:]
+}
+
+func (s *slidingLogState) take(now time.Time) rateLimitResult {
+	s.evict(now)
+	if len(s.log) >= s.params.limit {
+		retryAt := s.log[0].Add(s.params.window)
+		return rateLimitResult{reset: s.log[len(s.log)-1].Add(s.params.window), retryAt: retryAt}
+	}
+	s.log = append(s.log, now)
+	return rateLimitResult{
+		allowed:   true,
+		remaining: s.params.limit - len(s.log),
+		reset:     now.Add(s.params.window),
+	}
+}
+
+func (s *slidingLogState) expired(now time.Time) bool {
+	s.evict(now)
+	return len(s.log) == 0
+}
+
+type slidingCounterState struct {
+	params      *limitParams
+	windowStart time.Time
+	current     int
+	previous    int
+}
+
+func (s *slidingCounterState) advance(now time.Time) {
+	start := now.Truncate(s.params.window)
+	switch {
+	case start.Equal(s.windowStart):
+	case start.Equal(s.windowStart.Add(s.params.window)):
+		s.previous, s.current = s.current, 0
+	default:
+		s.previous, s.current = 0, 0
+	}
+	s.windowStart = start
+}
+
+func (s *slidingCounterState) take(now time.Time) rateLimitResult {
+	s.advance(now)
+	window := float64(s.params.window)
+	limit := float64(s.params.limit)
+	weight := (window - float64(now.Sub(s.windowStart))) / window
+	estimate := float64(s.previous)*weight + float64(s.current)
+	windowEnd := s.windowStart.Add(s.params.window)
+
+	if estimate+1 > limit {
+		return rateLimitResult{
+			reset:   windowEnd.Add(s.params.window),
+			retryAt: slidingCounterRetryAt(s.windowStart, s.params.window, s.params.limit, s.previous, s.current),
+		}
+	}
+
+	s.current++
+	return rateLimitResult{
+		allowed:   true,
+		remaining: int(limit - estimate - 1),
+		reset:     windowEnd.Add(s.params.window),
+	}
+}
+
+// slidingCounterRetryAt returns when one more request fits alongside current
+// requests in the window starting at windowStart
+func slidingCounterRetryAt(windowStart time.Time, window time.Duration, limit, previous, current int) time.Time {
+	if previous == 0 || current+1 > limit {
+		return windowStart.Add(window)
+	}
+	// The weighted previous count decays linearly through the window
+	needed := float64(window) - float64(limit-current-1)*float64(window)/float64(previous)
+	return windowStart.Add(time.Duration(math.Ceil(needed)))
+}
+
+func (s *slidingCounterState) expired(now time.Time) bool {
+	return !now.Before(s.windowStart.Add(2 * s.params.window))
+}
+
+type gcraState struct {
+	params *limitParams
+	// tat is the theoretical arrival time of the next request
+	tat time.Time
+}
+
+func (s *gcraState) take(now time.Time) rateLimitResult {
+	interval := s.params.window / time.Duration(s.params.limit)
+	tolerance := interval * time.Duration(s.params.burst)
+
+	tat := s.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-tolerance)
+
+	if now.Before(allowAt) {
+		remaining := int(now.Sub(tat.Add(-tolerance)) / interval)
+		if remaining < 0 {
+			remaining = 0
+		}
+		return rateLimitResult{remaining: remaining, reset: tat, retryAt: allowAt}
+	}
+
+	s.tat = newTat
+	return rateLimitResult{
+		allowed:   true,
+		remaining: int(now.Sub(allowAt) / interval),
+		reset:     newTat,
+	}
+}
+
+func (s *gcraState) expired(now time.Time) bool {
+	return !now.Before(s.tat)
+}
@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRedisStoreClosed is returned by Take after the store has been closed
+var ErrRedisStoreClosed = errors.New("redis rate limit store closed")
+
+type RedisRateLimitStoreConfig struct {
+	// Addr is the host:port of the Redis-compatible server
+	Addr string
+	// Password is sent with AUTH when set
+	Password string
+	// DB is selected with SELECT when non-zero
+	DB int
+	// Prefix is prepended to every key
+	Prefix string
+	// Limit is the number of requests per window
+	Limit int
+	// Window is the sliding window duration
+	Window time.Duration
+	// Timeout bounds dialing and each round trip
+	Timeout time.Duration
+	// PoolSize is the maximum number of idle connections kept open
+	PoolSize int
+
+	// now returns the current time (overridden in tests)
+	now func() time.Time
+}
+
+var DefaultRedisRateLimitStoreConfig = RedisRateLimitStoreConfig{
+	Addr:     "127.0.0.1:6379",
+	Prefix:   "ratelimit:",
+	Limit:    100,
+	Window:   time.Minute,
+	Timeout:  time.Second,
+	PoolSize: 10,
+}
+
+// RedisRateLimitStore shares a sliding-window counter between replicas using
+// INCR, PEXPIRE and GET, so it works with any server speaking the Redis
+// protocol. Window boundaries come from the local clock, so replicas should
+// keep their clocks in sync. Rejected requests still count toward the window.
+// Close it on shutdown, e.g. through a RateLimiter added with Router.UseCloser.
+type RedisRateLimitStore struct {
+	config RedisRateLimitStoreConfig
+	idle   chan *redisConn
+	mutex  sync.Mutex
+	closed bool
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisRateLimitStore creates a store; connections are opened on first use
+func NewRedisRateLimitStore(config RedisRateLimitStoreConfig) *RedisRateLimitStore {
+	if config.Addr == "" {
+		config.Addr = DefaultRedisRateLimitStoreConfig.Addr
+	}
+	if config.Prefix == "" {
+		config.Prefix = DefaultRedisRateLimitStoreConfig.Prefix
+	}
+	if config.Limit <= 0 {
+		config.Limit = DefaultRedisRateLimitStoreConfig.Limit
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultRedisRateLimitStoreConfig.Window
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultRedisRateLimitStoreConfig.Timeout
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = DefaultRedisRateLimitStoreConfig.PoolSize
+	}
+	if config.now == nil {
+		config.now = time.Now
+	}
+
+	return &RedisRateLimitStore{
+		config: config,
+		idle:   make(chan *redisConn, config.PoolSize),
+	}
+}
+
+// Take implements RateLimitStore
+func (s *RedisRateLimitStore) Take(key string) (RateLimitResult, error) {
+	now := s.config.now()
+	window := s.config.Window
+	windowStart := now.Truncate(window)
+	index := windowStart.UnixNano() / int64(window)
+
+	current := s.config.Prefix + key + ":" + strconv.FormatInt(index, 10)
+	previous := s.config.Prefix + key + ":" + strconv.FormatInt(index-1, 10)
+	ttl := strconv.FormatInt((2 * window).Milliseconds(), 10)
+
+	replies, err := s.pipeline(
+		[]string{"INCR", current},
+		[]string{"PEXPIRE", current, ttl},
+		[]string{"GET", previous},
+	)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	count, ok := replies[0].(int64)
+	if !ok {
+		return RateLimitResult{}, fmt.Errorf("redis: unexpected INCR reply %v", replies[0])
+	}
+	var prevCount int64
+	if v, ok := replies[2].(string); ok {
+		if prevCount, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return RateLimitResult{}, fmt.Errorf("redis: invalid counter %q", v)
+		}
+	}
+
+	weight := float64(window-now.Sub(windowStart)) / float64(window)
+	estimate := float64(prevCount)*weight + float64(count)
+	limit := s.config.Limit
+
+	result := RateLimitResult{
+		Limit: limit,
+		Reset: windowStart.Add(2 * window),
+	}
+	if estimate <= float64(limit) {
+		result.Allowed = true
+		result.Remaining = int(math.Floor(float64(limit) - estimate))
+	} else {
+		result.RetryAt = slidingCounterRetryAt(windowStart, window, limit, int(prevCount), int(count))
+	}
+	return result, nil
+}
+
+// Close closes idle connections; Take fails afterwards
+func (s *RedisRateLimitStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.idle)
+	for rc := range s.idle {
+		_ = rc.conn.Close()
+	}
+	return nil
+}
+
+// pipeline sends commands in one write and reads one reply per command
+func (s *RedisRateLimitStore) pipeline(commands ...[]string) ([]interface{}, error) {
+	rc, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+
+	_ = rc.conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	var buf []byte
+	for _, args := range commands {
+		buf = appendRESPCommand(buf, args)
+	}
+	if _, err := rc.conn.Write(buf); err != nil {
+		_ = rc.conn.Close()
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	var replyErr error
+	for i := range commands {
+		reply, err := readRESP(rc.reader)
+		if err != nil {
+			var redisErr redisError
+			if !errors.As(err, &redisErr) {
+				_ = rc.conn.Close()
+				return nil, err
+			}
+			if replyErr == nil {
+				replyErr = err
+			}
+		}
+		replies[i] = reply
+	}
+
+	s.put(rc)
+	return replies, replyErr
+}
+
+func (s *RedisRateLimitStore) get() (*redisConn, error) {
+	s.mutex.Lock()
+	closed := s.closed
+	s.mutex.Unlock()
+	if closed {
+		return nil, ErrRedisStoreClosed
+	}
+
+	select {
+	case rc, ok := <-s.idle:
+		if ok {
+			return rc, nil
+		}
+		return nil, ErrRedisStoreClosed
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", s.config.Addr, s.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	var setup [][]string
+	if s.config.Password != "" {
+		setup = append(setup, []string{"AUTH", s.config.Password})
+	}
+	if s.config.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.config.DB)})
+	}
+	_ = conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	for _, args := range setup {
+		if _, err := conn.Write(appendRESPCommand(nil, args)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if _, err := readRESP(rc.reader); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+func (s *RedisRateLimitStore) put(rc *redisConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		_ = rc.conn.Close()
+		return
+	}
+	select {
+	case s.idle <- rc:
+	default:
+		_ = rc.conn.Close()
+	}
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// appendRESPCommand encodes args as a RESP array of bulk strings
+func appendRESPCommand(buf []byte, args []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readRESP reads one reply: simple and bulk strings become string, integers
+// int64, arrays []interface{} and nil bulk strings nil
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", payload)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", payload)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// respServer is a minimal in-process stand-in for a Redis server
+type respServer struct {
+	listener net.Listener
+	password string
+	mu       sync.Mutex
+	values   map[string]int64
+	commands []string
+	open     int32
+}
+
+func newRESPServer(t *testing.T, password string) *respServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := &respServer{listener: listener, password: password, values: make(map[string]int64)}
+	go s.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return s
+}
+
+func (s *respServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *respServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// OpenConns returns the number of client connections being served
+func (s *respServer) OpenConns() int32 {
+	return atomic.LoadInt32(&s.open)
+}
+
+func (s *respServer) handle(conn net.Conn) {
+	atomic.AddInt32(&s.open, 1)
+	defer atomic.AddInt32(&s.open, -1)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		reply, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, args[0])
+		var out string
+		switch {
+		case args[0] == "AUTH":
+			authed = args[1] == s.password
+			out = "+OK\r\n"
+			if !authed {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required\r\n"
+		case args[0] == "INCR":
+			s.values[args[1]]++
+			out = ":" + strconv.FormatInt(s.values[args[1]], 10) + "\r\n"
+		case args[0] == "PEXPIRE":
+			out = ":1\r\n"
+		case args[0] == "GET":
+			if v, ok := s.values[args[1]]; ok {
+				str := strconv.FormatInt(v, 10)
+				out = "$" + strconv.Itoa(len(str)) + "\r\n" + str + "\r\n"
+			} else {
+				out = "$-1\r\n"
+			}
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisRateLimitStoreSharedAcrossReplicas(t *testing.T) {
+	server := newRESPServer(t, "secret")
+	clock := newFakeClock()
+
+	newReplica := func() (*fuselage.Router, *RedisRateLimitStore) {
+		store := NewRedisRateLimitStore(RedisRateLimitStoreConfig{
+			Addr:     server.Addr(),
+			Password: "secret",
+			Limit:    3,
+			Window:   time.Second,
+			now:      clock.Now,
+		})
+		t.Cleanup(func() { _ = store.Close() })
+		return newRateLimitRouter(RateLimitConfig{Store: store}), store
+	}
+	replica1, _ := newReplica()
+	replica2, _ := newReplica()
+
+	// Both replicas draw from the same limit
+	expectStatuses(t, replica1, 200, 200)
+	expectStatuses(t, replica2, 200, 429)
+	expectStatuses(t, replica1, 429)
+
+	// Two windows later the previous counts no longer apply
+	clock.Advance(2 * time.Second)
+	expectStatuses(t, replica2, 200)
+}
+
+func TestRedisRateLimitStoreTake(t *testing.T) {
+	server := newRESPServer(t, "")
+	clock := newFakeClock()
+	store := NewRedisRateLimitStore(RedisRateLimitStoreConfig{
+		Addr:   server.Addr(),
+		Limit:  2,
+		Window: time.Second,
+		now:    clock.Now,
+	})
+	defer store.Close()
+
+	result, err := store.Take("client")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Allowed || result.Remaining != 1 || result.Limit != 2 {
+		t.Errorf("Unexpected first result: %+v", result)
+	}
+
+	_, _ = store.Take("client")
+	result, _ = store.Take("client")
+	if result.Allowed || result.RetryAt.IsZero() {
+		t.Errorf("Expected third request to be denied with a retry time, got %+v", result)
+	}
+
+	server.mu.Lock()
+	got := server.values["ratelimit:client:"+strconv.FormatInt(clock.Now().Unix(), 10)]
+	server.mu.Unlock()
+	if got != 3 {
+		t.Errorf("Expected counter 3 in the current window key, got %d", got)
+	}
+}
+
+func TestRedisRateLimitStoreErrors(t *testing.T) {
+	server := newRESPServer(t, "secret")
+	store := NewRedisRateLimitStore(RedisRateLimitStoreConfig{Addr: server.Addr(), Password: "wrong"})
+	if _, err := store.Take("client"); err == nil {
+		t.Errorf("Expected authentication error")
+	}
+
+	router := newRateLimitRouter(RateLimitConfig{Store: store})
+	req := httptest.NewRequest("GET", "/test", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 on store failure, got %d", rec.Code)
+	}
+
+	_ = store.Close()
+	if _, err := store.Take("client"); err != ErrRedisStoreClosed {
+		t.Errorf("Expected ErrRedisStoreClosed, got %v", err)
+	}
+}
+
+func TestRateLimiterCloseReleasesConnections(t *testing.T) {
+	server := newRESPServer(t, "")
+
+	limiter := NewRateLimiter(RateLimitConfig{
+		Store: NewRedisRateLimitStore(RedisRateLimitStoreConfig{Addr: server.Addr(), Limit: 10}),
+	})
+	router := fuselage.New()
+	router.Use(limiter.Middleware())
+	router.AddCloser(limiter)
+	_ = router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+	expectStatuses(t, router, 200, 200)
+
+	if server.OpenConns() != 1 {
+		t.Fatalf("Expected one pooled connection, got %d", server.OpenConns())
+	}
+	if err := router.Close(); err != nil {
+		t.Fatalf("Unexpected close error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.OpenConns() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected connections to be closed, %d still open", server.OpenConns())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitResult is the limiter state after counting one request
+type RateLimitResult struct {
+	// Allowed reports whether the request is within the limit
+	Allowed bool
+	// Limit is the configured number of requests per window
+	Limit int
+	// Remaining is the number of requests still allowed right now
+	Remaining int
+	// Reset is when the limit is fully replenished
+	Reset time.Time
+	// RetryAt is when the next request will be allowed (zero if Allowed)
+	RetryAt time.Time
+}
+
+// RateLimitStore counts requests per key. Implementations backed by shared
+// storage let several replicas enforce one limit.
+type RateLimitStore interface {
+	// Take counts one request for key and reports whether it is allowed
+	Take(key string) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore keeps limiter state in process memory. Expired keys
+// are swept during Take once per window, so no background goroutine is needed.
+type MemoryRateLimitStore struct {
+	states    map[string]limitState
+	lastSweep time.Time
+	mutex     sync.Mutex
+	algorithm RateLimitAlgorithm
+	params    limitParams
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore creates an in-memory store using the Limit, Window,
+// Algorithm and Burst of config
+func NewMemoryRateLimitStore(config RateLimitConfig) *MemoryRateLimitStore {
+	if config.Limit <= 0 {
+		config.Limit = DefaultRateLimitConfig.Limit
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultRateLimitConfig.Window
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.now == nil {
+		config.now = time.Now
+	}
+
+	return &MemoryRateLimitStore{
+		states:    make(map[string]limitState),
+		lastSweep: config.now(),
+		algorithm: config.Algorithm,
+		params: limitParams{
+			limit:  config.Limit,
+			window: config.Window,
+			burst:  config.Burst,
+		},
+		now: config.now,
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(key string) (RateLimitResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= s.params.window {
+		s.sweep(now)
+	}
+
+	state, exists := s.states[key]
+	if !exists {
+		state = newLimitState(s.algorithm, &s.params, now)
+		s.states[key] = state
+	}
+	result := state.take(now)
+
+	return RateLimitResult{
+		Allowed:   result.allowed,
+		Limit:     s.params.limit,
+		Remaining: result.remaining,
+		Reset:     result.reset,
+		RetryAt:   result.retryAt,
+	}, nil
+}
+
+// Close discards all limiter state
+func (s *MemoryRateLimitStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.states = make(map[string]limitState)
+	return nil
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, state := range s.states {
+		if state.expired(now) {
+			delete(s.states, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -3,6 +3,9 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -84,4 +87,343 @@ func TestRateLimitDifferentIPs(t *testing.T) {
 	if rec2.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", rec2.Code)
 	}
-}
\ No newline at end of file
+}
+
+// fakeClock is a manually advanced clock for rate limit tests
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.t = f.t.Add(d)
+}
+
+func newRateLimitRouter(config RateLimitConfig) *fuselage.Router {
+	router := fuselage.New()
+	router.Use(RateLimitWithConfig(config))
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+	return router
+}
+
+func rateLimitStatus(router *fuselage.Router) int {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+	return rec.Code
+}
+
+// expectStatuses sends one request per expected status
+func expectStatuses(t *testing.T, router *fuselage.Router, expected ...int) {
+	t.Helper()
+	for i, want := range expected {
+		if got := rateLimitStatus(router); got != want {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, want, got)
+		}
+	}
+}
+
+func TestRateLimitFixedWindowBoundary(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{Limit: 2, Window: time.Second, now: clock.Now})
+
+	expectStatuses(t, router, 200, 200, 429)
+	clock.Advance(time.Second)
+	expectStatuses(t, router, 200, 200, 429)
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{
+		Limit:     2,
+		Window:    time.Second,
+		Algorithm: TokenBucket,
+		Burst:     4,
+		now:       clock.Now,
+	})
+
+	// The full burst is available up front
+	expectStatuses(t, router, 200, 200, 200, 200, 429)
+
+	// Tokens refill at Limit per Window
+	clock.Advance(500 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+
+	clock.Advance(10 * time.Second)
+	expectStatuses(t, router, 200, 200, 200, 200, 429)
+}
+
+func TestRateLimitSlidingWindowLog(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{
+		Limit:     2,
+		Window:    time.Second,
+		Algorithm: SlidingWindowLog,
+		now:       clock.Now,
+	})
+
+	expectStatuses(t, router, 200)
+	clock.Advance(600 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+
+	// Only the first request has left the window
+	clock.Advance(500 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+
+	// The second request leaves the window at 1.6s
+	clock.Advance(500 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+}
+
+func TestRateLimitSlidingWindowCounter(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{
+		Limit:     4,
+		Window:    time.Second,
+		Algorithm: SlidingWindowCounter,
+		now:       clock.Now,
+	})
+
+	expectStatuses(t, router, 200, 200, 200, 200, 429)
+
+	// A quarter into the next window, 3 of the previous 4 still count
+	clock.Advance(1250 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+
+	// Halfway through, the previous window counts as 2 plus 1 current
+	clock.Advance(250 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+
+	clock.Advance(250 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+}
+
+func TestRateLimitGCRA(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{
+		Limit:     4,
+		Window:    time.Second,
+		Algorithm: GCRA,
+		Burst:     2,
+		now:       clock.Now,
+	})
+
+	expectStatuses(t, router, 200, 200, 429)
+
+	// One emission interval (250ms) frees one slot
+	clock.Advance(250 * time.Millisecond)
+	expectStatuses(t, router, 200, 429)
+
+	clock.Advance(time.Second)
+	expectStatuses(t, router, 200, 200, 429)
+}
+
+func TestRateLimitAlgorithmsShareSkipper(t *testing.T) {
+	algorithms := []RateLimitAlgorithm{FixedWindow, TokenBucket, SlidingWindowLog, SlidingWindowCounter, GCRA}
+	for _, algorithm := range algorithms {
+		clock := newFakeClock()
+		router := newRateLimitRouter(RateLimitConfig{
+			Limit:     1,
+			Window:    time.Second,
+			Algorithm: algorithm,
+			Skipper: func(c *fuselage.Context) bool {
+				return c.Header("X-Skip") != ""
+			},
+			ErrorHandler: func(c *fuselage.Context, result RateLimitResult) error {
+				return c.String(http.StatusServiceUnavailable, "slow down")
+			},
+			now: clock.Now,
+		})
+
+		expectStatuses(t, router, 200, 503)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-Skip", "1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Algorithm %d: expected skipped request to pass, got %d", algorithm, rec.Code)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryRateLimitStore(RateLimitConfig{Limit: 2, Window: time.Minute, now: clock.Now})
+
+	result, err := store.Take("client")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Allowed || result.Limit != 2 || result.Remaining != 1 {
+		t.Errorf("Unexpected first result: %+v", result)
+	}
+	if !result.Reset.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Expected reset at the end of the window, got %v", result.Reset)
+	}
+
+	_, _ = store.Take("client")
+	result, _ = store.Take("client")
+	if result.Allowed || result.Remaining != 0 || !result.RetryAt.Equal(result.Reset) {
+		t.Errorf("Expected denied result retrying at reset, got %+v", result)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{Limit: 2, Window: time.Minute, now: clock.Now})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("RateLimit-Limit") != "2" {
+		t.Errorf("Expected RateLimit-Limit 2, got '%s'", rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("Expected RateLimit-Remaining 1, got '%s'", rec.Header().Get("RateLimit-Remaining"))
+	}
+	if rec.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("Expected RateLimit-Reset 60, got '%s'", rec.Header().Get("RateLimit-Reset"))
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Errorf("Expected no Retry-After on allowed request")
+	}
+
+	clock.Advance(15 * time.Second)
+	_ = rateLimitStatus(router)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "45" {
+		t.Errorf("Expected Retry-After 45, got '%s'", rec.Header().Get("Retry-After"))
+	}
+	if rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got '%s'", rec.Header().Get("RateLimit-Remaining"))
+	}
+}
+
+func TestRateLimitLegacyHeaders(t *testing.T) {
+	clock := newFakeClock()
+	router := newRateLimitRouter(RateLimitConfig{
+		Limit:   1,
+		Window:  time.Minute,
+		Headers: RateLimitHeadersLegacy,
+		now:     clock.Now,
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+
+	reset := strconv.FormatInt(clock.Now().Add(time.Minute).Unix(), 10)
+	if rec.Header().Get("X-RateLimit-Reset") != reset {
+		t.Errorf("Expected X-RateLimit-Reset %s, got '%s'", reset, rec.Header().Get("X-RateLimit-Reset"))
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "1" || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected only X-RateLimit-* headers")
+	}
+}
+
+func TestRateLimitErrorHandlerReceivesState(t *testing.T) {
+	clock := newFakeClock()
+	var got RateLimitResult
+	router := newRateLimitRouter(RateLimitConfig{
+		Limit:     1,
+		Window:    time.Second,
+		Algorithm: GCRA,
+		Headers:   RateLimitHeadersNone,
+		ErrorHandler: func(c *fuselage.Context, result RateLimitResult) error {
+			got = result
+			return c.String(http.StatusTooManyRequests, "slow down")
+		},
+		now: clock.Now,
+	})
+
+	expectStatuses(t, router, 200)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/test", nil))
+
+	if got.Allowed || got.Limit != 1 || !got.RetryAt.Equal(clock.Now().Add(time.Second)) {
+		t.Errorf("Unexpected limiter state: %+v", got)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got '%s'", rec.Header().Get("Retry-After"))
+	}
+	if rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected no rate limit headers")
+	}
+}
+
+func TestRateLimitStartsNoGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		router := newRateLimitRouter(RateLimitConfig{Limit: 1, Window: time.Millisecond})
+		expectStatuses(t, router, 200)
+	}
+
+	// The memory store sweeps inline, so nothing is left running to wait for
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("Expected at most %d goroutines, got %d", baseline, n)
+	}
+}
+
+func TestMemoryRateLimitStoreSweepsExpiredKeys(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryRateLimitStore(RateLimitConfig{Limit: 1, Window: time.Second, now: clock.Now})
+
+	for i := 0; i < 10; i++ {
+		_, _ = store.Take(strconv.Itoa(i))
+	}
+	clock.Advance(time.Second)
+	_, _ = store.Take("fresh")
+
+	store.mutex.Lock()
+	n := len(store.states)
+	store.mutex.Unlock()
+	if n != 1 {
+		t.Errorf("Expected expired keys to be swept, got %d keys", n)
+	}
+}
+
+func TestRateLimitBehindTrustedProxy(t *testing.T) {
+	router := newRateLimitRouter(RateLimitConfig{Limit: 1, Window: time.Minute})
+	extractor, _ := fuselage.NewIPExtractor("10.0.0.0/8")
+	router.SetIPExtractor(extractor)
+
+	send := func(client string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "10.0.0.1:443"
+		req.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send("198.51.100.1"); code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+	// Clients sharing the proxy are limited separately
+	if code := send("198.51.100.2"); code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", code)
+	}
+	if code := send("198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", code)
+	}
+}
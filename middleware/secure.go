@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// CSPNonce is a source placeholder replaced with the per-request nonce
+const CSPNonce = "{nonce}"
+
+type HSTSConfig struct {
+	// MaxAge in seconds (0 disables the header)
+	MaxAge int
+	// IncludeSubdomains adds the includeSubDomains directive
+	IncludeSubdomains bool
+	// Preload adds the preload directive
+	Preload bool
+	// TrustForwardedProto sends the header when X-Forwarded-Proto is https from
+	// any peer. Prefer Router.SetIPExtractor, which trusts only known proxies.
+	TrustForwardedProto bool
+}
+
+// CSP builds a Content-Security-Policy header value
+type CSP struct {
+	directives []cspDirective
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// NewCSP creates an empty policy
+func NewCSP() *CSP {
+	return &CSP{}
+}
+
+// Add appends sources to a directive, creating it if needed.
+// Use CSPNonce as a source to allow scripts or styles carrying the request nonce.
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	for i := range p.directives {
+		if p.directives[i].name == directive {
+			p.directives[i].sources = append(p.directives[i].sources, sources...)
+			return p
+		}
+	}
+	p.directives = append(p.directives, cspDirective{name: directive, sources: sources})
+	return p
+}
+
+// String renders the policy without a nonce
+func (p *CSP) String() string {
+	return p.build("")
+}
+
+func (p *CSP) usesNonce() bool {
+	for _, d := range p.directives {
+		for _, s := range d.sources {
+			if s == CSPNonce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *CSP) build(nonce string) string {
+	parts := make([]string, 0, len(p.directives))
+	for _, d := range p.directives {
+		var b strings.Builder
+		b.WriteString(d.name)
+		for _, s := range d.sources {
+			if s == CSPNonce {
+				if nonce == "" {
+					continue
+				}
+				s = "'nonce-" + nonce + "'"
+			}
+			b.WriteByte(' ')
+			b.WriteString(s)
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+type SecureConfig struct {
+	// XSSProtection sets X-XSS-Protection
+	XSSProtection string
+	// ContentTypeNosniff sets X-Content-Type-Options
+	ContentTypeNosniff string
+	// XFrameOptions sets X-Frame-Options
+	XFrameOptions string
+	// HSTS configures Strict-Transport-Security, sent only over HTTPS
+	HSTS HSTSConfig
+	// ContentSecurityPolicy sets Content-Security-Policy
+	ContentSecurityPolicy *CSP
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only
+	CSPReportOnly bool
+	// ReferrerPolicy sets Referrer-Policy
+	ReferrerPolicy string
+	// PermissionsPolicy sets Permissions-Policy
+	PermissionsPolicy string
+	// CrossOriginOpenerPolicy sets Cross-Origin-Opener-Policy
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy sets Cross-Origin-Embedder-Policy
+	CrossOriginEmbedderPolicy string
+	// CrossOriginResourcePolicy sets Cross-Origin-Resource-Policy
+	CrossOriginResourcePolicy string
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+}
+
+var DefaultSecureConfig = SecureConfig{
+	XSSProtection:      "0",
+	ContentTypeNosniff: "nosniff",
+	XFrameOptions:      "SAMEORIGIN",
+	HSTS: HSTSConfig{
+		MaxAge:            31536000,
+		IncludeSubdomains: true,
+	},
+	ReferrerPolicy:          "strict-origin-when-cross-origin",
+	CrossOriginOpenerPolicy: "same-origin",
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+}
+
+func Secure() fuselage.MiddlewareFunc {
+	return SecureWithConfig(DefaultSecureConfig)
+}
+
+// SecureWithConfig sets security headers. Empty fields leave the header unset.
+func SecureWithConfig(config SecureConfig) fuselage.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultSecureConfig.Skipper
+	}
+
+	hsts := ""
+	if config.HSTS.MaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(config.HSTS.MaxAge)
+		if config.HSTS.IncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTS.Preload {
+			hsts += "; preload"
+		}
+	}
+
+	cspHeader := fuselage.HeaderContentSecurityPolicy
+	if config.CSPReportOnly {
+		cspHeader = fuselage.HeaderContentSecurityPolicyReportOnly
+	}
+	staticCSP := ""
+	cspNonce := false
+	if config.ContentSecurityPolicy != nil {
+		cspNonce = config.ContentSecurityPolicy.usesNonce()
+		staticCSP = config.ContentSecurityPolicy.String()
+	}
+
+	headers := [][2]string{
+		{fuselage.HeaderXXSSProtection, config.XSSProtection},
+		{fuselage.HeaderXContentTypeOptions, config.ContentTypeNosniff},
+		{fuselage.HeaderXFrameOptions, config.XFrameOptions},
+		{fuselage.HeaderReferrerPolicy, config.ReferrerPolicy},
+		{fuselage.HeaderPermissionsPolicy, config.PermissionsPolicy},
+		{fuselage.HeaderCrossOriginOpenerPolicy, config.CrossOriginOpenerPolicy},
+		{fuselage.HeaderCrossOriginEmbedderPolicy, config.CrossOriginEmbedderPolicy},
+		{fuselage.HeaderCrossOriginResourcePolicy, config.CrossOriginResourcePolicy},
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			for _, h := range headers {
+				if h[1] != "" {
+					c.SetHeader(h[0], h[1])
+				}
+			}
+
+			if hsts != "" && isHTTPS(c, config.HSTS.TrustForwardedProto) {
+				c.SetHeader(fuselage.HeaderStrictTransportSecurity, hsts)
+			}
+
+			if cspNonce {
+				nonce := generateNonce()
+				ctx := context.WithValue(c.Request.Context(), fuselage.CSPNonceKey, nonce)
+				c.Request = c.Request.WithContext(ctx)
+				c.SetHeader(cspHeader, config.ContentSecurityPolicy.build(nonce))
+			} else if staticCSP != "" {
+				c.SetHeader(cspHeader, staticCSP)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GetCSPNonce returns the nonce for inline scripts and styles in this response
+func GetCSPNonce(c *fuselage.Context) string {
+	if nonce, ok := c.Request.Context().Value(fuselage.CSPNonceKey).(string); ok {
+		return nonce
+	}
+	return ""
+}
+
+func isHTTPS(c *fuselage.Context, trustForwardedProto bool) bool {
+	if c.Scheme() == "https" {
+		return true
+	}
+	return trustForwardedProto && strings.EqualFold(c.Header(fuselage.HeaderXForwardedProto), "https")
+}
+
+func generateNonce() string {
+	bytes := make([]byte, 16)
+	_, _ = rand.Read(bytes)
+	return base64.StdEncoding.EncodeToString(bytes)
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func TestSecure(t *testing.T) {
+	router := fuselage.New()
+	router.Use(Secure())
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected X-Content-Type-Options nosniff")
+	}
+	if rec.Header().Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Errorf("Expected X-Frame-Options SAMEORIGIN")
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("Expected no HSTS over plain HTTP")
+	}
+
+	req = httptest.NewRequest("GET", "/test", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Errorf("Expected HSTS over TLS, got '%s'", rec.Header().Get("Strict-Transport-Security"))
+	}
+}
+
+func TestSecureForwardedProto(t *testing.T) {
+	router := fuselage.New()
+	router.Use(SecureWithConfig(SecureConfig{
+		HSTS: HSTSConfig{MaxAge: 60, Preload: true, TrustForwardedProto: true},
+	}))
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Strict-Transport-Security") != "max-age=60; preload" {
+		t.Errorf("Expected HSTS from forwarded proto, got '%s'", rec.Header().Get("Strict-Transport-Security"))
+	}
+	if rec.Header().Get("X-Frame-Options") != "" {
+		t.Errorf("Expected unset fields to leave headers unset")
+	}
+}
+
+func TestSecureCSPNonce(t *testing.T) {
+	router := fuselage.New()
+	router.Use(SecureWithConfig(SecureConfig{
+		ContentSecurityPolicy: NewCSP().
+			Add("default-src", "'self'").
+			Add("script-src", "'self'", CSPNonce),
+		CrossOriginResourcePolicy: "same-site",
+		PermissionsPolicy:         "camera=()",
+	}))
+
+	router.GET("/page", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, GetCSPNonce(c))
+	})
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	nonce := rec.Body.String()
+	if nonce == "" {
+		t.Fatal("Expected nonce on the context")
+	}
+	expected := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'"
+	if rec.Header().Get("Content-Security-Policy") != expected {
+		t.Errorf("Expected CSP '%s', got '%s'", expected, rec.Header().Get("Content-Security-Policy"))
+	}
+	if rec.Header().Get("Cross-Origin-Resource-Policy") != "same-site" || rec.Header().Get("Permissions-Policy") != "camera=()" {
+		t.Errorf("Expected cross-origin and permissions policies")
+	}
+
+	rec2 := httptest.NewRecorder()
+	router.ServeHTTP(rec2, httptest.NewRequest("GET", "/page", nil))
+	if rec2.Body.String() == nonce {
+		t.Errorf("Expected a fresh nonce per request")
+	}
+}
+
+func TestSecureCSPReportOnly(t *testing.T) {
+	router := fuselage.New()
+	router.Use(SecureWithConfig(SecureConfig{
+		ContentSecurityPolicy: NewCSP().Add("default-src", "'none'"),
+		CSPReportOnly:         true,
+	}))
+
+	router.GET("/test", func(c *fuselage.Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Header().Get("Content-Security-Policy-Report-Only"), "default-src 'none'") {
+		t.Errorf("Expected report-only CSP header")
+	}
+	if rec.Header().Get("Content-Security-Policy") != "" {
+		t.Errorf("Expected no enforcing CSP header")
+	}
+}
@@ -0,0 +1,329 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+// ErrResponseCommitted is returned by the middleware when a session ID was
+// issued after the response was written, so its cookie never reached the client
+var ErrResponseCommitted = errors.New("session: response committed before the session cookie was set")
+
+// now is the clock used for timeouts, replaceable in tests
+var now = time.Now
+
+type Config struct {
+	// Store persists session data (default: in-memory store)
+	Store Store
+	// CookieName is the name of the session ID cookie
+	CookieName string
+	// CookiePath is the path attribute of the session ID cookie
+	CookiePath string
+	// CookieDomain is the domain attribute of the session ID cookie
+	CookieDomain string
+	// CookieSameSite is the SameSite attribute of the session ID cookie
+	CookieSameSite http.SameSite
+	// CookieInsecure allows the cookie over plain HTTP (development only)
+	CookieInsecure bool
+	// IdleTimeout expires sessions that have not been used for this long
+	IdleTimeout time.Duration
+	// AbsoluteTimeout expires sessions this long after creation regardless of activity
+	AbsoluteTimeout time.Duration
+	// Skipper defines a function to skip middleware
+	Skipper func(*fuselage.Context) bool
+	// ErrorHandler handles errors loading the session
+	ErrorHandler func(*fuselage.Context, error) error
+}
+
+var DefaultConfig = Config{
+	CookieName:      "session_id",
+	CookiePath:      "/",
+	CookieSameSite:  http.SameSiteLaxMode,
+	IdleTimeout:     30 * time.Minute,
+	AbsoluteTimeout: 24 * time.Hour,
+	Skipper: func(c *fuselage.Context) bool {
+		return false
+	},
+	ErrorHandler: func(c *fuselage.Context, err error) error {
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	},
+}
+
+// Session holds server-side state for one client
+type Session struct {
+	id      string
+	record  *Record
+	isNew   bool
+	changed bool
+	deleted bool
+	// previousID is deleted from the store once the regenerated session is saved
+	previousID string
+	// cookieLost records that an ID was issued after the response was committed
+	cookieLost bool
+
+	c      *fuselage.Context
+	config *Config
+	mutex  sync.RWMutex
+}
+
+func Middleware() fuselage.MiddlewareFunc {
+	return MiddlewareWithConfig(DefaultConfig)
+}
+
+// MiddlewareWithConfig loads the session before the handler and saves it afterwards
+func MiddlewareWithConfig(config Config) fuselage.MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultConfig.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultConfig.CookiePath
+	}
+	if config.CookieSameSite == http.SameSiteDefaultMode {
+		config.CookieSameSite = DefaultConfig.CookieSameSite
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultConfig.IdleTimeout
+	}
+	if config.AbsoluteTimeout <= 0 {
+		config.AbsoluteTimeout = DefaultConfig.AbsoluteTimeout
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultConfig.ErrorHandler
+	}
+
+	return func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			s, err := load(c, &config)
+			if err != nil {
+				return config.ErrorHandler(c, err)
+			}
+
+			ctx := context.WithValue(c.Request.Context(), fuselage.SessionKey, s)
+			c.Request = c.Request.WithContext(ctx)
+
+			// Save even when the handler fails so a regenerated session is not lost
+			err = next(c)
+			if saveErr := s.save(); err == nil {
+				err = saveErr
+			}
+			return err
+		}
+	}
+}
+
+// FromContext returns the session loaded by the middleware, or nil
+func FromContext(c *fuselage.Context) *Session {
+	if s, ok := c.Request.Context().Value(fuselage.SessionKey).(*Session); ok {
+		return s
+	}
+	return nil
+}
+
+func load(c *fuselage.Context, config *Config) (*Session, error) {
+	s := &Session{c: c, config: config}
+
+	if cookie, err := c.Cookie(config.CookieName); err == nil && validID(cookie.Value) {
+		record, err := config.Store.Load(cookie.Value)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil && !s.expired(record) {
+			s.id = cookie.Value
+			s.record = record
+			return s, nil
+		}
+		if record != nil {
+			if err := config.Store.Delete(cookie.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	t := now()
+	s.isNew = true
+	s.record = &Record{
+		Values:     make(map[string]interface{}),
+		CreatedAt:  t,
+		LastAccess: t,
+	}
+	return s, nil
+}
+
+// ID returns the session ID, or "" for a new session that has not been stored yet
+func (s *Session) ID() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.id
+}
+
+// IsNew returns true if the session was created during this request
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// CreatedAt returns the session creation time
+func (s *Session) CreatedAt() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.record.CreatedAt
+}
+
+// Get returns a session value
+func (s *Session) Get(key string) interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.record.Values[key]
+}
+
+// Set stores a session value. A new session sends its cookie here, so the first
+// Set must happen before the response is written. Custom types must be registered with gob.Register
+// when using a store that serializes values.
+func (s *Session) Set(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.record.Values[key] = value
+	s.changed = true
+	s.deleted = false
+	if s.id == "" {
+		s.issueID()
+	}
+}
+
+// Delete removes a session value
+func (s *Session) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.record.Values, key)
+	s.changed = true
+}
+
+// Regenerate replaces the session ID while keeping its values.
+// Call it whenever privileges change, such as on login, to prevent fixation,
+// and before the response is written so the new cookie reaches the client.
+// The old ID is removed from the store once the session is saved under the new one.
+func (s *Session) Regenerate() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.id != "" && !s.isNew && s.previousID == "" {
+		s.previousID = s.id
+	}
+	s.issueID()
+	s.changed = true
+	s.deleted = false
+	return nil
+}
+
+// Destroy deletes the session from the store and expires the cookie
+func (s *Session) Destroy() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range []string{s.id, s.previousID} {
+		if id != "" {
+			if err := s.config.Store.Delete(id); err != nil {
+				return err
+			}
+		}
+	}
+	s.record.Values = make(map[string]interface{})
+	s.id = ""
+	s.previousID = ""
+	s.deleted = true
+
+	cookie := s.cookie("")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	s.c.SetCookie(cookie)
+	return nil
+}
+
+func (s *Session) issueID() {
+	s.id = generateID()
+	if s.c.Response.Committed() {
+		s.cookieLost = true
+	}
+	s.c.SetCookie(s.cookie(s.id))
+}
+
+func (s *Session) cookie(value string) *http.Cookie {
+	cookie := fuselage.NewCookie(s.config.CookieName, value)
+	cookie.Path = s.config.CookiePath
+	cookie.Domain = s.config.CookieDomain
+	cookie.SameSite = s.config.CookieSameSite
+	cookie.Secure = !s.config.CookieInsecure
+	return cookie
+}
+
+func (s *Session) save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.deleted || s.id == "" || (s.isNew && !s.changed) {
+		return nil
+	}
+	// Storing a session the client cannot reference would also drop the old one
+	if s.cookieLost {
+		return ErrResponseCommitted
+	}
+
+	t := now()
+	s.record.LastAccess = t
+	ttl := s.config.IdleTimeout
+	if remaining := s.record.CreatedAt.Add(s.config.AbsoluteTimeout).Sub(t); remaining < ttl {
+		ttl = remaining
+	}
+	if ttl <= 0 {
+		if err := s.config.Store.Delete(s.id); err != nil {
+			return err
+		}
+	} else if err := s.config.Store.Save(s.id, s.record, ttl); err != nil {
+		return err
+	}
+	if s.previousID != "" {
+		if err := s.config.Store.Delete(s.previousID); err != nil {
+			return err
+		}
+		s.previousID = ""
+	}
+	return nil
+}
+
+func (s *Session) expired(record *Record) bool {
+	t := now()
+	return t.Sub(record.LastAccess) > s.config.IdleTimeout ||
+		t.Sub(record.CreatedAt) > s.config.AbsoluteTimeout
+}
+
+func generateID() string {
+	bytes := make([]byte, 32)
+	_, _ = rand.Read(bytes)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(32) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,202 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/k-tsurumaki/fuselage"
+)
+
+func newSessionRouter(config Config) *fuselage.Router {
+	router := fuselage.New()
+	router.Use(MiddlewareWithConfig(config))
+
+	router.GET("/login", func(c *fuselage.Context) error {
+		s := FromContext(c)
+		s.Set("user", "alice")
+		if err := s.Regenerate(); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, s.ID())
+	})
+	router.GET("/whoami", func(c *fuselage.Context) error {
+		user, _ := FromContext(c).Get("user").(string)
+		return c.String(http.StatusOK, user)
+	})
+	router.GET("/logout", func(c *fuselage.Context) error {
+		if err := FromContext(c).Destroy(); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, "bye")
+	})
+	return router
+}
+
+func doRequest(router *fuselage.Router, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSession(t *testing.T) {
+	router := newSessionRouter(Config{})
+
+	rec := doRequest(router, "/whoami", nil)
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("Expected no cookie for an unused session")
+	}
+
+	rec = doRequest(router, "/login", nil)
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("Expected session cookie to be set")
+	}
+	sessionCookie := cookies[len(cookies)-1]
+	if !sessionCookie.HttpOnly || !sessionCookie.Secure {
+		t.Errorf("Expected secure session cookie, got %+v", sessionCookie)
+	}
+
+	rec = doRequest(router, "/whoami", []*http.Cookie{sessionCookie})
+	if rec.Body.String() != "alice" {
+		t.Errorf("Expected 'alice', got '%s'", rec.Body.String())
+	}
+
+	doRequest(router, "/logout", []*http.Cookie{sessionCookie})
+	rec = doRequest(router, "/whoami", []*http.Cookie{sessionCookie})
+	if rec.Body.String() != "" {
+		t.Errorf("Expected destroyed session to be empty, got '%s'", rec.Body.String())
+	}
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	router := newSessionRouter(Config{})
+
+	rec := doRequest(router, "/login", nil)
+	cookies := rec.Result().Cookies()
+	first := cookies[len(cookies)-1]
+
+	rec = doRequest(router, "/login", []*http.Cookie{first})
+	cookies = rec.Result().Cookies()
+	second := cookies[len(cookies)-1]
+
+	if first.Value == second.Value {
+		t.Fatal("Expected session ID to change on regeneration")
+	}
+
+	rec = doRequest(router, "/whoami", []*http.Cookie{first})
+	if rec.Body.String() != "" {
+		t.Errorf("Expected old session ID to be invalid, got '%s'", rec.Body.String())
+	}
+	rec = doRequest(router, "/whoami", []*http.Cookie{second})
+	if rec.Body.String() != "alice" {
+		t.Errorf("Expected values to survive regeneration, got '%s'", rec.Body.String())
+	}
+}
+
+func TestSessionRegenerateSurvivesHandlerError(t *testing.T) {
+	store := NewMemoryStore()
+	router := newSessionRouter(Config{Store: store})
+	router.GET("/login-fails", func(c *fuselage.Context) error {
+		s := FromContext(c)
+		s.Set("user", "bob")
+		if err := s.Regenerate(); err != nil {
+			return err
+		}
+		return errors.New("audit log unavailable")
+	})
+
+	rec := doRequest(router, "/login", nil)
+	cookies := rec.Result().Cookies()
+	first := cookies[len(cookies)-1]
+
+	rec = doRequest(router, "/login-fails", []*http.Cookie{first})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", rec.Code)
+	}
+	cookies = rec.Result().Cookies()
+	second := cookies[len(cookies)-1]
+	if second.Value == first.Value {
+		t.Fatal("Expected a regenerated session cookie")
+	}
+
+	rec = doRequest(router, "/whoami", []*http.Cookie{second})
+	if rec.Body.String() != "bob" {
+		t.Errorf("Expected regenerated session to be saved despite the error, got '%s'", rec.Body.String())
+	}
+	if record, _ := store.Load(first.Value); record != nil {
+		t.Errorf("Expected old session ID to be deleted after save")
+	}
+}
+
+func TestSessionSetAfterResponseWritten(t *testing.T) {
+	store := NewMemoryStore()
+	var handlerErr error
+	router := fuselage.New()
+	router.Use(func(next fuselage.HandlerFunc) fuselage.HandlerFunc {
+		return func(c *fuselage.Context) error {
+			handlerErr = next(c)
+			return handlerErr
+		}
+	})
+	router.Use(MiddlewareWithConfig(Config{Store: store}))
+	router.GET("/late", func(c *fuselage.Context) error {
+		err := c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+		FromContext(c).Set("user", "carol")
+		return err
+	})
+
+	rec := doRequest(router, "/late", nil)
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("Expected no cookie after the response was written")
+	}
+	if !errors.Is(handlerErr, ErrResponseCommitted) {
+		t.Errorf("Expected ErrResponseCommitted, got %v", handlerErr)
+	}
+	if store.Len() != 0 {
+		t.Errorf("Expected unreachable session not to be stored")
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	router := newSessionRouter(Config{
+		IdleTimeout:     10 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+	})
+
+	rec := doRequest(router, "/login", nil)
+	cookies := rec.Result().Cookies()
+	cookie := cookies[len(cookies)-1]
+
+	// Activity within the idle timeout keeps the session alive
+	for i := 0; i < 6; i++ {
+		current = current.Add(9 * time.Minute)
+		if body := doRequest(router, "/whoami", []*http.Cookie{cookie}).Body.String(); body != "alice" {
+			t.Fatalf("Expected session to be alive after %d minutes, got '%s'", (i+1)*9, body)
+		}
+	}
+
+	// The absolute timeout expires the session despite activity
+	current = current.Add(9 * time.Minute)
+	if body := doRequest(router, "/whoami", []*http.Cookie{cookie}).Body.String(); body != "" {
+		t.Errorf("Expected absolute timeout to expire the session, got '%s'", body)
+	}
+
+	rec = doRequest(router, "/login", nil)
+	cookies = rec.Result().Cookies()
+	cookie = cookies[len(cookies)-1]
+	current = current.Add(11 * time.Minute)
+	if body := doRequest(router, "/whoami", []*http.Cookie{cookie}).Body.String(); body != "" {
+		t.Errorf("Expected idle timeout to expire the session, got '%s'", body)
+	}
+}
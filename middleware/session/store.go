@@ -0,0 +1,226 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is the persisted state of a session
+type Record struct {
+	Values     map[string]interface{}
+	CreatedAt  time.Time
+	LastAccess time.Time
+}
+
+// Store persists session records
+type Store interface {
+	// Load returns the record for id, or nil if it does not exist or has expired
+	Load(id string) (*Record, error)
+	// Save stores the record for id, expiring it after ttl
+	Save(id string, record *Record, ttl time.Duration) error
+	// Delete removes the record for id
+	Delete(id string) error
+}
+
+type memoryEntry struct {
+	record  *Record
+	expires time.Time
+}
+
+// MemoryStore keeps sessions in process memory. Expired entries are evicted
+// on access and swept periodically during saves.
+type MemoryStore struct {
+	entries   map[string]memoryEntry
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an in-memory session store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:   make(map[string]memoryEntry),
+		lastSweep: now(),
+	}
+}
+
+// Load implements Store
+func (m *MemoryStore) Load(id string) (*Record, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, ok := m.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	if now().After(entry.expires) {
+		delete(m.entries, id)
+		return nil, nil
+	}
+	return copyRecord(entry.record), nil
+}
+
+// Save implements Store
+func (m *MemoryStore) Save(id string, record *Record, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t := now()
+	m.entries[id] = memoryEntry{record: copyRecord(record), expires: t.Add(ttl)}
+
+	if t.Sub(m.lastSweep) > time.Minute {
+		for key, entry := range m.entries {
+			if t.After(entry.expires) {
+				delete(m.entries, key)
+			}
+		}
+		m.lastSweep = t
+	}
+	return nil
+}
+
+// Delete implements Store
+func (m *MemoryStore) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.entries, id)
+	return nil
+}
+
+// Len returns the number of stored sessions, including expired ones not yet evicted
+func (m *MemoryStore) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.entries)
+}
+
+func copyRecord(record *Record) *Record {
+	values := make(map[string]interface{}, len(record.Values))
+	for k, v := range record.Values {
+		values[k] = v
+	}
+	return &Record{
+		Values:     values,
+		CreatedAt:  record.CreatedAt,
+		LastAccess: record.LastAccess,
+	}
+}
+
+type fileEntry struct {
+	Record  Record
+	Expires time.Time
+}
+
+// FileStore keeps each session in a gob-encoded file inside a directory
+type FileStore struct {
+	dir string
+}
+
+const fileStorePrefix = "session_"
+
+// NewFileStore creates a filesystem session store, creating dir if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Load implements Store
+func (f *FileStore) Load(id string) (*Record, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry fileEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, err
+	}
+	if now().After(entry.Expires) {
+		return nil, f.Delete(id)
+	}
+	if entry.Record.Values == nil {
+		entry.Record.Values = make(map[string]interface{})
+	}
+	return &entry.Record, nil
+}
+
+// Save implements Store. Files are written atomically via rename.
+func (f *FileStore) Save(id string, record *Record, ttl time.Duration) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	entry := fileEntry{Record: *record, Expires: now().Add(ttl)}
+	if err := gob.NewEncoder(&buf).Encode(&entry); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".tmp_")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete implements Store
+func (f *FileStore) Delete(id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Sweep removes expired session files
+func (f *FileStore) Sweep() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), fileStorePrefix) {
+			continue
+		}
+		if _, err := f.Load(strings.TrimPrefix(e.Name(), fileStorePrefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileStore) path(id string) (string, error) {
+	if !validID(id) {
+		return "", errors.New("session: invalid session id")
+	}
+	return filepath.Join(f.dir, fileStorePrefix+id), nil
+}
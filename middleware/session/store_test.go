@@ -0,0 +1,73 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	id := generateID()
+
+	record := &Record{Values: map[string]interface{}{"count": 1}}
+	_ = store.Save(id, record, time.Minute)
+
+	// Mutating the original must not affect the stored copy
+	record.Values["count"] = 2
+
+	loaded, err := store.Load(id)
+	if err != nil || loaded == nil {
+		t.Fatalf("Expected record, got %v (%v)", loaded, err)
+	}
+	if loaded.Values["count"] != 1 {
+		t.Errorf("Expected count 1, got %v", loaded.Values["count"])
+	}
+
+	_ = store.Save(id, record, -time.Second)
+	if loaded, _ := store.Load(id); loaded != nil {
+		t.Error("Expected expired record to be evicted")
+	}
+	if store.Len() != 0 {
+		t.Errorf("Expected empty store, got %d entries", store.Len())
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	id := generateID()
+
+	created := time.Now().Truncate(time.Second)
+	record := &Record{
+		Values:    map[string]interface{}{"user": "alice", "admin": true},
+		CreatedAt: created,
+	}
+	if err := store.Save(id, record, time.Minute); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := store.Load(id)
+	if err != nil || loaded == nil {
+		t.Fatalf("Expected record, got %v (%v)", loaded, err)
+	}
+	if loaded.Values["user"] != "alice" || loaded.Values["admin"] != true {
+		t.Errorf("Unexpected values: %v", loaded.Values)
+	}
+	if !loaded.CreatedAt.Equal(created) {
+		t.Errorf("Expected CreatedAt %v, got %v", created, loaded.CreatedAt)
+	}
+
+	_ = store.Save(id, record, -time.Second)
+	if err := store.Sweep(); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if loaded, _ := store.Load(id); loaded != nil {
+		t.Error("Expected expired record to be removed")
+	}
+
+	if _, err := store.Load("../../etc/passwd"); err == nil {
+		t.Error("Expected invalid session id to be rejected")
+	}
+}
@@ -0,0 +1,275 @@
+package fuselage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrRendererNotRegistered is returned by Context.Render when the router has no renderer
+var ErrRendererNotRegistered = errors.New("renderer not registered")
+
+// Renderer renders named templates
+type Renderer interface {
+	Render(w io.Writer, name string, data interface{}, c *Context) error
+}
+
+// SetRenderer sets the renderer used by Context.Render.
+// On a group it sets the renderer of the root router.
+func (r *Router) SetRenderer(renderer Renderer) {
+	r.rootRouter().renderer = renderer
+}
+
+// Render renders a template with the router's renderer and sends it as HTML.
+// The template is rendered before anything is written, so errors leave the
+// response untouched.
+func (c *Context) Render(status int, name string, data interface{}) error {
+	if c.router == nil || c.router.renderer == nil {
+		return ErrRendererNotRegistered
+	}
+
+	var buf bytes.Buffer
+	if err := c.router.renderer.Render(&buf, name, data, c); err != nil {
+		return err
+	}
+
+	if c.Response.Header().Get(HeaderContentType) == "" {
+		c.Response.Header().Set(HeaderContentType, "text/html; charset=utf-8")
+	}
+	c.Response.WriteHeader(status)
+	_, err := c.Response.Write(buf.Bytes())
+	return err
+}
+
+// HTMLRendererConfig configures an HTMLRenderer
+type HTMLRendererConfig struct {
+	// FS is the file system templates are loaded from
+	FS fs.FS
+	// Extension selects template files
+	Extension string
+	// Layouts is the directory of layout templates, e.g. "layouts/base.html".
+	// Pages fill a layout's blocks with {{define "content"}}.
+	Layouts string
+	// DefaultLayout is the layout pages are rendered within, e.g. "base".
+	// Render selects another with "page@layout" and none with "page@".
+	// Empty renders pages on their own unless a layout is named.
+	DefaultLayout string
+	// Partials is the directory of templates shared by every page, e.g. "partials"
+	Partials string
+	// Funcs are added to the default function map
+	Funcs template.FuncMap
+	// DevMode reloads templates on every render
+	DevMode bool
+}
+
+// DefaultHTMLRendererConfig is the default HTML renderer configuration
+var DefaultHTMLRendererConfig = HTMLRendererConfig{
+	Extension: ".html",
+	Layouts:   "layouts",
+	Partials:  "partials",
+}
+
+// HTMLRenderer renders html/template pages loaded from an fs.FS.
+// Pages are named by their path without extension, e.g. "users/show".
+// Partials are available to every page by name, e.g. {{template "partials/nav" .}}.
+type HTMLRenderer struct {
+	config HTMLRendererConfig
+
+	mu sync.RWMutex
+	// pages holds each page parsed within every layout, keyed by page and
+	// layout name; the empty layout name is the page on its own
+	pages map[string]map[string]*template.Template
+}
+
+// NewHTMLRenderer parses all templates, returning the first parse error
+func NewHTMLRenderer(config HTMLRendererConfig) (*HTMLRenderer, error) {
+	if config.FS == nil {
+		return nil, errors.New("fuselage: HTMLRendererConfig.FS is required")
+	}
+	if config.Extension == "" {
+		config.Extension = DefaultHTMLRendererConfig.Extension
+	}
+	if config.Layouts == "" {
+		config.Layouts = DefaultHTMLRendererConfig.Layouts
+	}
+	if config.Partials == "" {
+		config.Partials = DefaultHTMLRendererConfig.Partials
+	}
+
+	r := &HTMLRenderer{config: config}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Render implements Renderer. The name may select a layout, e.g.
+// "users/show@admin", or render the page without one, e.g. "login@".
+func (r *HTMLRenderer) Render(w io.Writer, name string, data interface{}, c *Context) error {
+	if r.config.DevMode {
+		if err := r.load(); err != nil {
+			return err
+		}
+	}
+
+	page, layout, named := strings.Cut(name, "@")
+	if !named {
+		layout = r.config.DefaultLayout
+	}
+
+	r.mu.RLock()
+	layouts, ok := r.pages[page]
+	t, found := layouts[layout]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("fuselage: template %q not found", page)
+	}
+	if !found {
+		return fmt.Errorf("fuselage: layout %q not found", layout)
+	}
+
+	if layout != "" {
+		return t.ExecuteTemplate(w, r.config.Layouts+"/"+layout, data)
+	}
+	return t.ExecuteTemplate(w, page, data)
+}
+
+func (r *HTMLRenderer) load() error {
+	var layouts, partials, pages []string
+
+	err := fs.WalkDir(r.config.FS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, r.config.Extension) {
+			return nil
+		}
+		switch {
+		case strings.HasPrefix(p, r.config.Layouts+"/"):
+			layouts = append(layouts, p)
+		case strings.HasPrefix(p, r.config.Partials+"/"):
+			partials = append(partials, p)
+		default:
+			pages = append(pages, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	funcs := template.FuncMap{
+		"url":  URLFor,
+		"dict": templateDict,
+	}
+	for name, fn := range r.config.Funcs {
+		funcs[name] = fn
+	}
+
+	base := template.New("").Funcs(funcs)
+	for _, file := range partials {
+		if err := r.parse(base, file); err != nil {
+			return err
+		}
+	}
+
+	// Each layout gets its own set so layouts can define the same blocks
+	sets := map[string]*template.Template{"": base}
+	for _, file := range layouts {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := r.parse(t, file); err != nil {
+			return err
+		}
+		sets[strings.TrimPrefix(r.templateName(file), r.config.Layouts+"/")] = t
+	}
+	if _, ok := sets[r.config.DefaultLayout]; !ok {
+		return fmt.Errorf("fuselage: layout %q not found", r.config.DefaultLayout)
+	}
+
+	// Pages are parsed after their layout so their blocks take precedence
+	loaded := make(map[string]map[string]*template.Template, len(pages))
+	for _, file := range pages {
+		variants := make(map[string]*template.Template, len(sets))
+		for layout, set := range sets {
+			t, err := set.Clone()
+			if err != nil {
+				return err
+			}
+			if err := r.parse(t, file); err != nil {
+				return err
+			}
+			variants[layout] = t
+		}
+		loaded[r.templateName(file)] = variants
+	}
+
+	r.mu.Lock()
+	r.pages = loaded
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *HTMLRenderer) parse(t *template.Template, file string) error {
+	src, err := fs.ReadFile(r.config.FS, file)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(r.templateName(file)).Parse(string(src))
+	return err
+}
+
+func (r *HTMLRenderer) templateName(file string) string {
+	return strings.TrimSuffix(file, r.config.Extension)
+}
+
+// URLFor builds a path from a route pattern, filling :params and a trailing
+// *wildcard in order, e.g. URLFor("/users/:id/posts/:slug", 7, "hello").
+// Parameter values are path-escaped; wildcard values keep their slashes.
+func URLFor(pattern string, params ...interface{}) string {
+	parts := strings.Split(pattern, "/")
+	i := 0
+	for n, part := range parts {
+		if i >= len(params) {
+			break
+		}
+		switch {
+		case strings.HasPrefix(part, ":"):
+			parts[n] = url.PathEscape(fmt.Sprint(params[i]))
+			i++
+		case strings.HasPrefix(part, "*"):
+			segments := strings.Split(strings.TrimPrefix(fmt.Sprint(params[i]), "/"), "/")
+			for j := range segments {
+				segments[j] = url.PathEscape(segments[j])
+			}
+			parts[n] = path.Join(segments...)
+			i++
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// templateDict builds a map from key/value pairs for passing several values to a partial
+func templateDict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict requires key/value pairs")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
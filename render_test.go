@@ -0,0 +1,176 @@
+package fuselage
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTemplateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`<title>{{block "title" .}}Admin{{end}}</title>{{template "partials/nav" .}}<main>{{block "content" .}}{{end}}</main>`)},
+		"partials/nav.html": {Data: []byte(`<nav><a href="{{url "/users/:id" .ID}}">profile</a></nav>`)},
+		"users/show.html":   {Data: []byte(`{{define "title"}}{{upper .Name}}{{end}}{{define "content"}}<p>{{.Name}}</p>{{end}}`)},
+		"home.html":         {Data: []byte(`{{define "content"}}home{{end}}`)},
+	}
+}
+
+func TestContext_Render(t *testing.T) {
+	renderer, err := NewHTMLRenderer(HTMLRendererConfig{
+		FS:            newTemplateFS(),
+		DefaultLayout: "base",
+		Funcs:         template.FuncMap{"upper": strings.ToUpper},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := New()
+	router.SetRenderer(renderer)
+	admin := router.Group("/admin")
+	_ = admin.GET("/users/:id", func(c *Context) error {
+		return c.Render(http.StatusOK, "users/show", map[string]interface{}{
+			"ID":   c.Param("id"),
+			"Name": "<alice>",
+		})
+	})
+	_ = admin.GET("/", func(c *Context) error {
+		return c.Render(http.StatusAccepted, "home", map[string]interface{}{"ID": 1})
+	})
+	_ = admin.GET("/missing", func(c *Context) error {
+		return c.Render(http.StatusOK, "nope", nil)
+	})
+
+	req := httptest.NewRequest("GET", "/admin/users/a%20b", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expected := `<title>&lt;ALICE&gt;</title><nav><a href="/users/a%20b">profile</a></nav><main><p>&lt;alice&gt;</p></main>`
+	if w.Body.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("Expected HTML content type, got '%s'", w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", http.NoBody))
+	if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), "<title>Admin</title>") {
+		t.Errorf("Expected default title block, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/missing", http.NoBody))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 for unknown template, got %d", w.Code)
+	}
+}
+
+func TestContext_RenderWithoutRenderer(t *testing.T) {
+	router := New()
+	var renderErr error
+	_ = router.GET("/", func(c *Context) error {
+		renderErr = c.Render(http.StatusOK, "home", nil)
+		return renderErr
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+	if renderErr != ErrRendererNotRegistered {
+		t.Errorf("Expected ErrRendererNotRegistered, got %v", renderErr)
+	}
+}
+
+func TestHTMLRenderer_Layouts(t *testing.T) {
+	fsys := newTemplateFS()
+	fsys["layouts/plain.html"] = &fstest.MapFile{Data: []byte(`<body>{{block "content" .}}{{end}}</body>`)}
+	fsys["login.html"] = &fstest.MapFile{Data: []byte(`<form></form>`)}
+	fsys["users/row.html"] = &fstest.MapFile{Data: []byte(`<tr>{{.}}</tr>`)}
+
+	renderer, err := NewHTMLRenderer(HTMLRendererConfig{
+		FS:            fsys,
+		DefaultLayout: "base",
+		Funcs:         template.FuncMap{"upper": strings.ToUpper},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     interface{}
+		expected string
+	}{
+		{"home@plain", nil, "<body>home</body>"},
+		{"login@", nil, "<form></form>"},
+		{"users/row@", "alice", "<tr>alice</tr>"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := renderer.Render(&out, tt.name, tt.data, nil); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.name, tt.expected, out.String())
+		}
+	}
+
+	if err := renderer.Render(io.Discard, "home@missing", nil, nil); err == nil {
+		t.Error("Expected error for unknown layout")
+	}
+	if _, err := NewHTMLRenderer(HTMLRendererConfig{FS: fstest.MapFS{"home.html": {}}, DefaultLayout: "missing"}); err == nil {
+		t.Error("Expected error for unknown default layout")
+	}
+}
+
+func TestHTMLRenderer_DevMode(t *testing.T) {
+	fsys := fstest.MapFS{"page.html": {Data: []byte("v1")}}
+
+	cached, err := NewHTMLRenderer(HTMLRendererConfig{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := NewHTMLRenderer(HTMLRendererConfig{FS: fsys, DevMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["page.html"] = &fstest.MapFile{Data: []byte("v2")}
+
+	var out strings.Builder
+	_ = cached.Render(&out, "page", nil, nil)
+	if out.String() != "v1" {
+		t.Errorf("Expected parsed template to be reused, got '%s'", out.String())
+	}
+
+	out.Reset()
+	_ = dev.Render(&out, "page", nil, nil)
+	if out.String() != "v2" {
+		t.Errorf("Expected dev mode to reload, got '%s'", out.String())
+	}
+}
+
+func TestHTMLRenderer_ParseError(t *testing.T) {
+	_, err := NewHTMLRenderer(HTMLRendererConfig{FS: fstest.MapFS{"bad.html": {Data: []byte("{{.Broken")}}})
+	if err == nil {
+		t.Error("Expected parse error")
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	tests := map[string]string{
+		URLFor("/users/:id", 42):                   "/users/42",
+		URLFor("/users/:id/posts/:slug", 7, "a/b"): "/users/7/posts/a%2Fb",
+		URLFor("/static/*filepath", "css/app.css"): "/static/css/app.css",
+		URLFor("/users/:id"):                       "/users/:id",
+	}
+	for got, expected := range tests {
+		if got != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package fuselage
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter wraps http.ResponseWriter and tracks the response state
+// regardless of whether handlers use Context helpers or write directly.
+type ResponseWriter struct {
+	Writer    http.ResponseWriter
+	status    int
+	size      int64
+	committed bool
+	writtenAt time.Time
+}
+
+// NewResponseWriter creates a ResponseWriter wrapping w
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{Writer: w}
+}
+
+// Header returns the response header map
+func (w *ResponseWriter) Header() http.Header {
+	return w.Writer.Header()
+}
+
+// WriteHeader sends the status code. Calls after the response is committed are ignored.
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.committed {
+		return
+	}
+	// Informational responses other than 101 do not commit the final status
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.Writer.WriteHeader(code)
+		return
+	}
+	w.status = code
+	w.committed = true
+	w.writtenAt = time.Now()
+	w.Writer.WriteHeader(code)
+}
+
+// Write writes the body, committing a 200 status if none was sent
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.Writer.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom copies r into the response, using the underlying io.ReaderFrom when available
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := io.Copy(w.Writer, r)
+	w.size += n
+	return n, err
+}
+
+// Flush sends buffered data to the client
+func (w *ResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError sends buffered data to the client and reports whether flushing is supported
+func (w *ResponseWriter) FlushError() error {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.Writer).Flush()
+}
+
+// Hijack lets the caller take over the connection
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.Writer).Hijack()
+	if err == nil {
+		w.committed = true
+		if w.writtenAt.IsZero() {
+			w.writtenAt = time.Now()
+		}
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.Writer.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.Writer
+}
+
+// Status returns the status code sent, or 0 if nothing has been sent
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Committed returns true once the status has been sent
+func (w *ResponseWriter) Committed() bool {
+	return w.committed
+}
+
+// WrittenAt returns the time the response was committed
+func (w *ResponseWriter) WrittenAt() time.Time {
+	return w.writtenAt
+}
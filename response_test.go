@@ -0,0 +1,119 @@
+package fuselage
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter_DirectWrite(t *testing.T) {
+	var status int
+	var written bool
+	var size int64
+
+	router := New()
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			err := next(c)
+			status, written, size = c.Status(), c.IsWritten(), c.Response.Size()
+			return err
+		}
+	})
+	_ = router.GET("/raw", func(c *Context) error {
+		c.Response.WriteHeader(http.StatusAccepted)
+		_, err := c.Response.Write([]byte("accepted"))
+		return err
+	})
+
+	req := httptest.NewRequest("GET", "/raw", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if status != http.StatusAccepted || !written {
+		t.Errorf("Expected tracked status 202 and written, got %d %v", status, written)
+	}
+	if size != int64(len("accepted")) {
+		t.Errorf("Expected size %d, got %d", len("accepted"), size)
+	}
+}
+
+func TestResponseWriter_WrappedHandler(t *testing.T) {
+	var status int
+
+	router := New()
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			err := next(c)
+			status = c.Status()
+			return err
+		}
+	})
+	_ = router.GET("/mounted", WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})))
+
+	req := httptest.NewRequest("GET", "/mounted", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGone || status != http.StatusGone {
+		t.Errorf("Expected status 410 recorded, got response %d tracked %d", w.Code, status)
+	}
+}
+
+func TestResponseWriter_DuplicateWriteHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := NewResponseWriter(w)
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.WriteHeader(http.StatusInternalServerError)
+	_, _ = rw.ReadFrom(strings.NewReader("body"))
+
+	if w.Code != http.StatusCreated || rw.Status() != http.StatusCreated {
+		t.Errorf("Expected first status to win, got %d", w.Code)
+	}
+	if rw.Size() != 4 || w.Body.String() != "body" {
+		t.Errorf("Expected 4 bytes copied, got %d", rw.Size())
+	}
+	if rw.WrittenAt().IsZero() {
+		t.Error("Expected WrittenAt to be recorded")
+	}
+}
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (h hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestResponseWriter_ResponseController(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec)
+
+	if err := http.NewResponseController(rw).Flush(); err != nil {
+		t.Errorf("Expected flush through wrapper, got %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("Expected underlying recorder to be flushed")
+	}
+	if _, _, err := http.NewResponseController(rw).Hijack(); err == nil {
+		t.Error("Expected hijack to be unsupported by the recorder")
+	}
+
+	rw = NewResponseWriter(hijackableRecorder{httptest.NewRecorder()})
+	conn, _, err := http.NewResponseController(rw).Hijack()
+	if err != nil {
+		t.Fatalf("Expected hijack to succeed, got %v", err)
+	}
+	_ = conn.Close()
+	if !rw.Committed() {
+		t.Error("Expected hijacked response to be committed")
+	}
+}
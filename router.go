@@ -3,20 +3,30 @@ package fuselage
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 // Router handles HTTP routing with middleware support
 type Router struct {
 	routes                  map[string]map[string]routeEntry
 	middleware              []MiddlewareFunc
+	groupMiddleware         []MiddlewareFunc
 	notFoundHandler         HandlerFunc
 	methodNotAllowedHandler HandlerFunc
+	renderer                Renderer
+	ipExtractor             *IPExtractor
+	closers                 []io.Closer
+	closeMutex              sync.Mutex
 	prefix                  string
+	// root is the router serving a group's routes (nil for the root router)
+	root *Router
 }
 
 type routeEntry struct {
+	path        string
 	handler     HandlerFunc
 	middlewares []MiddlewareFunc
 }
@@ -30,29 +40,94 @@ func New() *Router {
 	}
 }
 
-// Use adds middleware to the router (LIFO order)
+// ClosableMiddleware is middleware owning resources, such as connections,
+// that must be released when the router is closed
+type ClosableMiddleware interface {
+	io.Closer
+	Middleware() MiddlewareFunc
+}
+
+// Use adds middleware to the router (LIFO order).
+// On a group it applies to routes registered on the group afterwards.
 func (r *Router) Use(middleware MiddlewareFunc) {
+	if r.root != nil {
+		r.groupMiddleware = append(r.groupMiddleware, middleware)
+		return
+	}
 	r.middleware = append(r.middleware, middleware)
 }
 
-// Group creates a route group with prefix and middleware
+// UseCloser adds the middleware of m like Use and registers m with AddCloser,
+// so it is closed with the router
+func (r *Router) UseCloser(m ClosableMiddleware) {
+	r.Use(m.Middleware())
+	r.AddCloser(m)
+}
+
+// AddCloser registers a resource, such as middleware owning background
+// goroutines or connections, to be closed by Close
+func (r *Router) AddCloser(closer io.Closer) {
+	root := r.rootRouter()
+	root.closeMutex.Lock()
+	defer root.closeMutex.Unlock()
+	root.closers = append(root.closers, closer)
+}
+
+// Close closes registered resources in reverse order of registration.
+// Server.Shutdown calls it when the router is the server's handler.
+func (r *Router) Close() error {
+	root := r.rootRouter()
+	root.closeMutex.Lock()
+	closers := root.closers
+	root.closers = nil
+	root.closeMutex.Unlock()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Group creates a route group with prefix and middleware.
+// Routes registered on the group are served by the parent router.
 func (r *Router) Group(prefix string, middlewares ...MiddlewareFunc) *Router {
+	groupMiddleware := append([]MiddlewareFunc{}, r.groupMiddleware...)
 	group := &Router{
-		routes:                  make(map[string]map[string]routeEntry),
-		middleware:              append(r.middleware, middlewares...),
+		routes:                  r.routes,
+		groupMiddleware:         append(groupMiddleware, middlewares...),
 		notFoundHandler:         r.notFoundHandler,
 		methodNotAllowedHandler: r.methodNotAllowedHandler,
 		prefix:                  r.prefix + prefix,
+		root:                    r.rootRouter(),
 	}
 	return group
 }
 
-// SetNotFoundHandler sets custom 404 handler
+// rootRouter returns the router that serves this router's routes
+func (r *Router) rootRouter() *Router {
+	if r.root != nil {
+		return r.root
+	}
+	return r
+}
+
+// SetIPExtractor configures how Context.RealIP, Scheme and Host read
+// forwarding headers. By default no proxy is trusted.
+func (r *Router) SetIPExtractor(extractor *IPExtractor) {
+	r.rootRouter().ipExtractor = extractor
+}
+
+// SetNotFoundHandler sets custom 404 handler.
+// It has no effect on a group: unmatched requests are handled by the root router.
 func (r *Router) SetNotFoundHandler(handler HandlerFunc) {
 	r.notFoundHandler = handler
 }
 
-// SetMethodNotAllowedHandler sets custom 405 handler
+// SetMethodNotAllowedHandler sets custom 405 handler.
+// It has no effect on a group: the root router answers disallowed methods.
 func (r *Router) SetMethodNotAllowedHandler(handler HandlerFunc) {
 	r.methodNotAllowedHandler = handler
 }
@@ -61,25 +136,32 @@ func (r *Router) SetMethodNotAllowedHandler(handler HandlerFunc) {
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx := &Context{
 		Request:  req,
-		Response: w,
-		status:   0,
-		written:  false,
+		Response: NewResponseWriter(w),
+		router:   r,
 	}
 
-	handler, params, routeMiddlewares := r.findHandler(req.Method, req.URL.Path)
-	if handler == nil {
-		if r.hasPath(req.URL.Path) {
-			handler = r.methodNotAllowedHandler
-		} else {
-			handler = r.notFoundHandler
-		}
+	var handler HandlerFunc
+	var routeMiddlewares []MiddlewareFunc
+	entry, params := r.findHandler(req.Method, req.URL.Path)
+	if entry != nil {
+		handler, routeMiddlewares = entry.handler, entry.middlewares
+		ctx.route = entry.path
+	} else if r.hasPath(req.URL.Path) {
+		handler = r.methodNotAllowedHandler
+	} else {
+		handler = r.notFoundHandler
 	}
 
 	ctx.params = params
 	finalHandler := r.applyMiddlewareWithRoute(handler, routeMiddlewares)
 
-	if err := finalHandler(ctx); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := finalHandler(ctx); err != nil && !ctx.Response.Committed() {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			http.Error(ctx.Response, httpErr.Message, httpErr.Code)
+			return
+		}
+		http.Error(ctx.Response, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -109,9 +191,11 @@ func (r *Router) addRoute(method, path string, handler HandlerFunc, middlewares
 		return fmt.Errorf("route %s already exists", key)
 	}
 
+	all := append([]MiddlewareFunc{}, r.groupMiddleware...)
 	r.routes[method][fullPath] = routeEntry{
+		path:        fullPath,
 		handler:     handler,
-		middlewares: middlewares,
+		middlewares: append(all, middlewares...),
 	}
 	return nil
 }
@@ -136,22 +220,41 @@ func (r *Router) DELETE(path string, handler HandlerFunc, middlewares ...Middlew
 	return r.addRoute(DELETE, path, handler, middlewares...)
 }
 
-// findHandler locates the handler, path parameters, and route-specific middlewares for a given HTTP method and path.
-func (r *Router) findHandler(method, path string) (HandlerFunc, map[string]string, []MiddlewareFunc) {
+// HEAD registers a HEAD route
+func (r *Router) HEAD(path string, handler HandlerFunc, middlewares ...MiddlewareFunc) error {
+	return r.addRoute(HEAD, path, handler, middlewares...)
+}
+
+// findHandler locates the route entry and path parameters for a given HTTP method and path.
+func (r *Router) findHandler(method, path string) (*routeEntry, map[string]string) {
 	if methodRoutes, exists := r.routes[method]; exists {
 		// If there is an exact match, return it
 		if entry, found := methodRoutes[path]; found {
-			return entry.handler, nil, entry.middlewares
+			return &entry, nil
 		}
-		// Otherwise, try to match with path parameters (e.g., /users/:id)
+		// Otherwise, try to match with path parameters (e.g., /users/:id),
+		// falling back to wildcard routes (e.g., /static/*filepath). Among
+		// wildcards the one with the most segments wins, so nested mounts
+		// take precedence regardless of map iteration order.
+		var wildcard *routeEntry
+		var wildcardParams map[string]string
 		for routePath, entry := range methodRoutes {
 			if p := matchRoute(routePath, path); p != nil {
-				return entry.handler, p, entry.middlewares
+				if !strings.Contains(routePath, "*") {
+					return &entry, p
+				}
+				if wildcard == nil || strings.Count(routePath, "/") > strings.Count(wildcard.path, "/") {
+					e := entry
+					wildcard, wildcardParams = &e, p
+				}
 			}
 		}
+		if wildcard != nil {
+			return wildcard, wildcardParams
+		}
 	}
 	// No match found
-	return nil, nil, nil
+	return nil, nil
 }
 
 func (r *Router) hasPath(path string) bool {
@@ -169,13 +272,22 @@ func matchRoute(routePath, requestPath string) map[string]string {
 	routeParts := strings.Split(routePath, "/")
 	requestParts := strings.Split(requestPath, "/")
 
-	if len(routeParts) != len(requestParts) {
+	last := routeParts[len(routeParts)-1]
+	catchAll := strings.HasPrefix(last, "*")
+	if len(routeParts) != len(requestParts) && (!catchAll || len(requestParts) < len(routeParts)) {
 		return nil
 	}
 
 	params := make(map[string]string)
 	for i, part := range routeParts {
-		if strings.HasPrefix(part, ":") {
+		if catchAll && i == len(routeParts)-1 {
+			// A trailing *name captures the rest of the path
+			name := part[1:]
+			if name == "" {
+				name = "*"
+			}
+			params[name] = strings.Join(requestParts[i:], "/")
+		} else if strings.HasPrefix(part, ":") {
 			params[part[1:]] = requestParts[i]
 		} else if part != requestParts[i] {
 			return nil
@@ -185,6 +297,14 @@ func matchRoute(routePath, requestPath string) map[string]string {
 	return params
 }
 
+// WrapHandler adapts an http.Handler to a HandlerFunc
+func WrapHandler(h http.Handler) HandlerFunc {
+	return func(c *Context) error {
+		h.ServeHTTP(c.Response, c.Request)
+		return nil
+	}
+}
+
 func defaultNotFound(c *Context) error {
 	return c.String(http.StatusNotFound, "Not Found")
 }
@@ -1,6 +1,9 @@
 package fuselage
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 )
@@ -22,3 +25,22 @@ func NewServer(addr string, handler http.Handler) *Server {
 		},
 	}
 }
+
+// Shutdown gracefully stops the server, then closes the handler if it
+// implements io.Closer (as Router does)
+func (s *Server) Shutdown(ctx context.Context) error {
+	return errors.Join(s.Server.Shutdown(ctx), s.closeHandler())
+}
+
+// Close immediately stops the server, then closes the handler if it
+// implements io.Closer
+func (s *Server) Close() error {
+	return errors.Join(s.Server.Close(), s.closeHandler())
+}
+
+func (s *Server) closeHandler() error {
+	if closer, ok := s.Handler.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package fuselage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// tickerResource owns a background goroutine until closed
+type tickerResource struct {
+	done   chan struct{}
+	closed chan struct{}
+}
+
+func newTickerResource() *tickerResource {
+	r := &tickerResource{done: make(chan struct{}), closed: make(chan struct{})}
+	go func() {
+		defer close(r.closed)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *tickerResource) Close() error {
+	close(r.done)
+	<-r.closed
+	return nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// waitForGoroutines fails the test unless the goroutine count drops back to baseline
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("Expected at most %d goroutines, got %d\n%s",
+				baseline, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerShutdownClosesRouter(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	router := New()
+	router.AddCloser(newTickerResource())
+	_ = router.GET("/test", func(c *Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := NewServer(listener.Addr().String(), router)
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Get("http://" + listener.Addr().String() + "/test")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	client.CloseIdleConnections()
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected shutdown error: %v", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+
+	waitForGoroutines(t, baseline)
+}
+
+type closableMiddleware struct {
+	closed bool
+}
+
+func (m *closableMiddleware) Middleware() MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			c.SetHeader("X-Closable", "yes")
+			return next(c)
+		}
+	}
+}
+
+func (m *closableMiddleware) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestRouterUseCloser(t *testing.T) {
+	router := New()
+	m := &closableMiddleware{}
+	router.UseCloser(m)
+	_ = router.GET("/test", func(c *Context) error {
+		return c.String(http.StatusOK, "OK")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/test", http.NoBody))
+	if w.Header().Get("X-Closable") != "yes" {
+		t.Errorf("Expected middleware to run")
+	}
+
+	if err := router.Close(); err != nil || !m.closed {
+		t.Errorf("Expected middleware to be closed with the router, got %v", err)
+	}
+}
+
+func TestRouterClose(t *testing.T) {
+	router := New()
+	api := router.Group("/api")
+
+	var order []string
+	errFirst := errors.New("first failed")
+	router.AddCloser(closerFunc(func() error {
+		order = append(order, "first")
+		return errFirst
+	}))
+	api.AddCloser(closerFunc(func() error {
+		order = append(order, "second")
+		return nil
+	}))
+
+	err := router.Close()
+	if !errors.Is(err, errFirst) {
+		t.Errorf("Expected close error to be returned, got %v", err)
+	}
+	if len(order) != 2 || order[0] != "second" || order[1] != "first" {
+		t.Errorf("Expected closers in reverse order, got %v", order)
+	}
+
+	if err := router.Close(); err != nil || len(order) != 2 {
+		t.Errorf("Expected second Close to be a no-op")
+	}
+}
@@ -0,0 +1,119 @@
+package fuselage
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SPAConfig configures single-page application serving
+type SPAConfig struct {
+	// Index is the document served for client-side routes
+	Index string
+	// APIPrefixes lists path prefixes that keep the normal NotFound handler, e.g. "/api"
+	APIPrefixes []string
+	// AssetMaxAge is the Cache-Control max-age in seconds for fingerprinted assets
+	AssetMaxAge int
+	// IsHashedAsset reports whether a file name contains a content hash,
+	// making it safe to cache indefinitely
+	IsHashedAsset func(name string) bool
+}
+
+// DefaultSPAConfig is the default single-page application configuration
+var DefaultSPAConfig = SPAConfig{
+	Index:         "index.html",
+	AssetMaxAge:   31536000,
+	IsHashedAsset: isHashedAsset,
+}
+
+// hashedAssetPattern matches bundler fingerprints such as app.3f2a9c1b.js or index-BdX9k2Lq.css
+var hashedAssetPattern = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.[A-Za-z0-9]+$`)
+
+func isHashedAsset(name string) bool {
+	m := hashedAssetPattern.FindStringSubmatch(path.Base(name))
+	return m != nil && strings.ContainsAny(m[1], "0123456789")
+}
+
+// SPA serves a single-page application from fsys for requests no route matches
+func (r *Router) SPA(fsys fs.FS) {
+	r.SPAWithConfig(fsys, DefaultSPAConfig)
+}
+
+// SPAWithConfig serves existing files from fsys and falls back to the index
+// document for unmatched GET and HEAD requests accepting text/html, so deep
+// links reach the client-side router. Requests under APIPrefixes and other
+// unmatched requests still get the NotFound handler. Fingerprinted assets are
+// cached as immutable while the index document is always revalidated.
+// Call it on the root router after SetNotFoundHandler.
+func (r *Router) SPAWithConfig(fsys fs.FS, config SPAConfig) {
+	if config.Index == "" {
+		config.Index = DefaultSPAConfig.Index
+	}
+	if config.AssetMaxAge <= 0 {
+		config.AssetMaxAge = DefaultSPAConfig.AssetMaxAge
+	}
+	if config.IsHashedAsset == nil {
+		config.IsHashedAsset = DefaultSPAConfig.IsHashedAsset
+	}
+
+	notFound := r.notFoundHandler
+	files := &staticServer{fsys: fsys, config: StaticConfig{Precompressed: true}, notFoundHandler: notFound}
+	assetCacheControl := "public, max-age=" + strconv.Itoa(config.AssetMaxAge) + ", immutable"
+
+	r.notFoundHandler = func(c *Context) error {
+		method := c.Request.Method
+		if method != GET && method != HEAD || hasPathPrefix(c.Request.URL.Path, config.APIPrefixes) {
+			return notFound(c)
+		}
+
+		if name, ok := cleanStaticPath(c.Request.URL.Path); ok {
+			if info, err := fs.Stat(fsys, name); err == nil && !info.IsDir() {
+				if config.IsHashedAsset(name) {
+					c.SetHeader(HeaderCacheControl, assetCacheControl)
+				} else {
+					c.SetHeader(HeaderCacheControl, "no-cache")
+				}
+				return files.serveFile(c, name, info)
+			}
+		}
+
+		// The fallback depends on Accept, so shared caches must key on it
+		addStaticVary(c.Response.Header(), HeaderAccept)
+		if !acceptsHTML(c.Header(HeaderAccept)) {
+			return notFound(c)
+		}
+		info, err := fs.Stat(fsys, config.Index)
+		if err != nil || info.IsDir() {
+			return notFound(c)
+		}
+		c.SetHeader(HeaderCacheControl, "no-cache")
+		return files.serveFile(c, config.Index, info)
+	}
+}
+
+// hasPathPrefix reports whether p equals a prefix or lies beneath it
+func hasPathPrefix(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == "" {
+			continue
+		}
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsHTML(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/html", "application/xhtml+xml":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package fuselage
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRouter_SPA(t *testing.T) {
+	router := New()
+	_ = router.GET("/api/users", func(c *Context) error {
+		return c.JSON(http.StatusOK, []string{"alice"})
+	})
+	router.SPAWithConfig(fstest.MapFS{
+		"index.html":             {Data: []byte("<div id=root></div>")},
+		"assets/app.3f2a9c1b.js": {Data: []byte("app()")},
+		"favicon.ico":            {Data: []byte("icon")},
+	}, SPAConfig{APIPrefixes: []string{"/api"}})
+
+	html := "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8"
+
+	w := serveStatic(router, "GET", "/settings/profile", "Accept", html)
+	if w.Code != http.StatusOK || w.Body.String() != "<div id=root></div>" {
+		t.Fatalf("Expected index for deep link, got %d '%s'", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected index to be revalidated, got '%s'", w.Header().Get("Cache-Control"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected text/html, got '%s'", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("Expected Vary: Accept on the index fallback, got '%s'", w.Header().Get("Vary"))
+	}
+
+	w = serveStatic(router, "GET", "/assets/app.3f2a9c1b.js", "Accept", "*/*")
+	if w.Body.String() != "app()" || w.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("Expected immutable hashed asset, got '%s' '%s'", w.Body.String(), w.Header().Get("Cache-Control"))
+	}
+
+	w = serveStatic(router, "GET", "/favicon.ico")
+	if w.Body.String() != "icon" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected unhashed asset to be revalidated, got '%s'", w.Header().Get("Cache-Control"))
+	}
+
+	w = serveStatic(router, "GET", "/api/missing", "Accept", html)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected API prefix to keep 404, got %d", w.Code)
+	}
+
+	w = serveStatic(router, "GET", "/api/users")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "alice") {
+		t.Errorf("Expected API route to be served, got %d", w.Code)
+	}
+
+	w = serveStatic(router, "GET", "/assets/missing.js", "Accept", "*/*")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected missing asset to 404, got %d", w.Code)
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("Expected Vary: Accept on the 404 fallback, got '%s'", w.Header().Get("Vary"))
+	}
+
+	w = serveStatic(router, "POST", "/settings", "Accept", html)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected non-GET requests to 404, got %d", w.Code)
+	}
+}
+
+func TestRouter_SPACustomNotFound(t *testing.T) {
+	router := New()
+	router.SetNotFoundHandler(func(c *Context) error {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	})
+	router.SPA(fstest.MapFS{"index.html": {Data: []byte("spa")}})
+
+	w := serveStatic(router, "GET", "/missing.json", "Accept", "application/json")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "not found") {
+		t.Errorf("Expected custom NotFound handler, got %d '%s'", w.Code, w.Body.String())
+	}
+}
+
+func TestIsHashedAsset(t *testing.T) {
+	tests := map[string]bool{
+		"assets/app.3f2a9c1b.js":  true,
+		"index-BdX9k2Lq.css":      true,
+		"chunk.a1b2c3d4e5.js.map": false,
+		"app.js":                  false,
+		"jquery-versioned.js":     false,
+		"index.html":              false,
+	}
+	for name, expected := range tests {
+		if isHashedAsset(name) != expected {
+			t.Errorf("isHashedAsset(%q) = %v, expected %v", name, !expected, expected)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package fuselage
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamingUnsupported is returned when the response writer cannot be flushed
+var ErrStreamingUnsupported = errors.New("streaming unsupported by response writer")
+
+// SSEEvent represents a single Server-Sent Events message
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// SSEStream writes Server-Sent Events to the client
+type SSEStream struct {
+	c          *Context
+	controller *http.ResponseController
+	mutex      sync.Mutex
+}
+
+// SSE starts a Server-Sent Events stream on the response.
+// Writes fail with the request context error once the client goes away.
+func (c *Context) SSE() (*SSEStream, error) {
+	// Check before committing headers so the caller can still send an error
+	if !supportsFlush(c.Response) {
+		return nil, ErrStreamingUnsupported
+	}
+	controller := http.NewResponseController(c.Response)
+
+	c.Response.Header().Set(HeaderContentType, "text/event-stream")
+	c.Response.Header().Set(HeaderCacheControl, "no-cache")
+	c.Response.Header().Set(HeaderConnection, "keep-alive")
+	c.Response.Header().Del(HeaderContentLength)
+	c.SetStatus(http.StatusOK)
+
+	if err := controller.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &SSEStream{c: c, controller: controller}, nil
+}
+
+// supportsFlush reports whether the innermost writer can be flushed.
+// Wrappers exposing Unwrap are assumed to delegate Flush to the writer they wrap.
+func supportsFlush(w http.ResponseWriter) bool {
+	for {
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+			w = u.Unwrap()
+			continue
+		}
+		switch w.(type) {
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+		}
+		return false
+	}
+}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting client
+func (s *SSEStream) LastEventID() string {
+	return s.c.Header(HeaderLastEventID)
+}
+
+// Done returns a channel that is closed when the client disconnects
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.c.Request.Context().Done()
+}
+
+// Send writes an event. Empty event and id fields are omitted.
+func (s *SSEStream) Send(event, id, data string) error {
+	return s.SendEvent(SSEEvent{ID: id, Event: event, Data: data})
+}
+
+// SendEvent writes a fully specified event
+func (s *SSEStream) SendEvent(ev SSEEvent) error {
+	var b strings.Builder
+	if ev.ID != "" {
+		writeSSEField(&b, "id", ev.ID)
+	}
+	if ev.Event != "" {
+		writeSSEField(&b, "event", ev.Event)
+	}
+	if ev.Retry > 0 {
+		writeSSEField(&b, "retry", strconv.FormatInt(ev.Retry.Milliseconds(), 10))
+	}
+	for _, line := range strings.Split(ev.Data, "\n") {
+		writeSSEField(&b, "data", line)
+	}
+	b.WriteByte('\n')
+	return s.write(b.String())
+}
+
+// Retry tells the client how long to wait before reconnecting
+func (s *SSEStream) Retry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// Comment writes a comment line, which clients ignore
+func (s *SSEStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return s.write(b.String())
+}
+
+// Heartbeat sends a comment every interval until the returned stop function
+// is called or the client disconnects. Non-positive intervals default to 15 seconds.
+func (s *SSEStream) Heartbeat(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Comment("heartbeat"); err != nil {
+					return
+				}
+			case <-quit:
+				return
+			case <-s.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-finished
+		})
+	}
+}
+
+func (s *SSEStream) write(payload string) error {
+	if err := s.c.Request.Context().Err(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := io.WriteString(s.c.Response, payload); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+func writeSSEField(b *strings.Builder, name, value string) {
+	// Field values must not contain line breaks
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	b.WriteString(name)
+	b.WriteString(": ")
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// ParseSSE reads an event stream and returns the dispatched events the way a
+// browser would: blocks without data are not dispatched, and the last event ID
+// and retry interval carry over to later events. It is intended for tests and
+// simple clients.
+func ParseSSE(r io.Reader) ([]SSEEvent, error) {
+	var (
+		events      []SSEEvent
+		eventType   string
+		data        []string
+		lastEventID string
+		retry       time.Duration
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if line == "" {
+			if len(data) > 0 {
+				events = append(events, SSEEvent{
+					ID:    lastEventID,
+					Event: eventType,
+					Data:  strings.Join(data, "\n"),
+					Retry: retry,
+				})
+			}
+			eventType, data = "", nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				lastEventID = value
+			}
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			// Values that are not all digits, or overflow a Duration, are ignored
+			if ms, err := strconv.ParseUint(value, 10, 43); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	return events, scanner.Err()
+}
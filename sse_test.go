@@ -0,0 +1,163 @@
+package fuselage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContext_SSE(t *testing.T) {
+	router := New()
+	_ = router.GET("/events", func(c *Context) error {
+		stream, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		if err := stream.Retry(3 * time.Second); err != nil {
+			return err
+		}
+		if err := stream.Comment("hello"); err != nil {
+			return err
+		}
+		if err := stream.Send("update", "1", "first"); err != nil {
+			return err
+		}
+		return stream.Send("", "2", "line one\nline two")
+	})
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got '%s'", w.Header().Get("Content-Type"))
+	}
+
+	events, err := ParseSSE(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %v", err)
+	}
+	// The retry and comment blocks carry no data and are not dispatched
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Event != "update" || events[0].ID != "1" || events[0].Data != "first" || events[0].Retry != 3*time.Second {
+		t.Errorf("Unexpected event: %+v", events[0])
+	}
+	if events[1].Data != "line one\nline two" || events[1].ID != "2" {
+		t.Errorf("Expected multi-line data, got %+v", events[1])
+	}
+}
+
+func TestParseSSE(t *testing.T) {
+	stream := "id: 7\n\n" +
+		"retry: soon\n\n" +
+		"event: ping\ndata: a\n\n" +
+		"retry: 500\n\n" +
+		"data: b\n\n" +
+		"data: unterminated\n"
+
+	events, err := ParseSSE(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", events)
+	}
+	if events[0] != (SSEEvent{ID: "7", Event: "ping", Data: "a"}) {
+		t.Errorf("Expected last event ID to carry over and bad retry to be ignored, got %+v", events[0])
+	}
+	if events[1] != (SSEEvent{ID: "7", Data: "b", Retry: 500 * time.Millisecond}) {
+		t.Errorf("Expected event type to reset and retry to carry over, got %+v", events[1])
+	}
+}
+
+func TestContext_SSE_LastEventID(t *testing.T) {
+	router := New()
+	_ = router.GET("/events", func(c *Context) error {
+		stream, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		return stream.Send("resume", "", stream.LastEventID())
+	})
+
+	req := httptest.NewRequest("GET", "/events", http.NoBody)
+	req.Header.Set("Last-Event-ID", "42")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	events, _ := ParseSSE(w.Body)
+	if len(events) != 1 || events[0].Data != "42" {
+		t.Errorf("Expected resumed event with data '42', got %+v", events)
+	}
+}
+
+func TestContext_SSE_ClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", http.NoBody).WithContext(ctx)
+	c := &Context{
+		Request:  req,
+		Response: NewResponseWriter(httptest.NewRecorder()),
+	}
+
+	stream, err := c.SSE()
+	if err != nil {
+		t.Fatalf("Expected stream, got error: %v", err)
+	}
+	stop := stream.Heartbeat(time.Millisecond)
+	defer stop()
+
+	cancel()
+
+	select {
+	case <-stream.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected Done to be closed after cancellation")
+	}
+	if err := stream.Send("update", "", "data"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+type plainWriter struct {
+	header http.Header
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *plainWriter) WriteHeader(int)             {}
+
+func TestContext_SSE_Unsupported(t *testing.T) {
+	c := &Context{
+		Request:  httptest.NewRequest("GET", "/events", http.NoBody),
+		Response: NewResponseWriter(&plainWriter{header: http.Header{}}),
+	}
+
+	if _, err := c.SSE(); err != ErrStreamingUnsupported {
+		t.Errorf("Expected ErrStreamingUnsupported, got %v", err)
+	}
+	if c.Response.Committed() {
+		t.Errorf("Expected response to stay uncommitted so an error can still be sent")
+	}
+}
+
+func TestContext_SSE_HeartbeatDefaultInterval(t *testing.T) {
+	c := &Context{
+		Request:  httptest.NewRequest("GET", "/events", http.NoBody),
+		Response: NewResponseWriter(httptest.NewRecorder()),
+	}
+
+	stream, err := c.SSE()
+	if err != nil {
+		t.Fatalf("Expected stream, got error: %v", err)
+	}
+	// A zero interval must not panic in time.NewTicker
+	stop := stream.Heartbeat(0)
+	stop()
+}
@@ -0,0 +1,278 @@
+package fuselage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StaticConfig configures static file serving
+type StaticConfig struct {
+	// Index is the file served for directory requests
+	Index string
+	// Browse lists directory contents when a directory has no index file
+	Browse bool
+	// Precompressed serves a "<name>.gz" sibling to clients accepting gzip
+	Precompressed bool
+	// MaxAge sets Cache-Control max-age in seconds (0 omits the header)
+	MaxAge int
+}
+
+// DefaultStaticConfig is the default static file configuration
+var DefaultStaticConfig = StaticConfig{
+	Index:         "index.html",
+	Precompressed: true,
+}
+
+// Static serves files from dir under prefix
+func (r *Router) Static(prefix, dir string, middlewares ...MiddlewareFunc) error {
+	return r.StaticFS(prefix, os.DirFS(dir), middlewares...)
+}
+
+// StaticFS serves files from fsys under prefix. Use fs.Sub to serve a
+// subdirectory of an embed.FS.
+func (r *Router) StaticFS(prefix string, fsys fs.FS, middlewares ...MiddlewareFunc) error {
+	return r.StaticWithConfig(prefix, fsys, DefaultStaticConfig, middlewares...)
+}
+
+// StaticWithConfig serves files from fsys under prefix with Range,
+// If-Modified-Since and ETag support
+func (r *Router) StaticWithConfig(prefix string, fsys fs.FS, config StaticConfig, middlewares ...MiddlewareFunc) error {
+	if config.Index == "" {
+		config.Index = DefaultStaticConfig.Index
+	}
+
+	fileServer := &staticServer{fsys: fsys, config: config}
+	base := strings.TrimSuffix(prefix, "/")
+	route := base + "/*filepath"
+	if err := r.GET(route, fileServer.serve, middlewares...); err != nil {
+		return err
+	}
+	if err := r.HEAD(route, fileServer.serve, middlewares...); err != nil {
+		return err
+	}
+	if base == "" {
+		return nil
+	}
+
+	// The bare prefix does not match the wildcard route, so send it to the root directory
+	if err := r.GET(base, redirectTrailingSlash, middlewares...); err != nil {
+		return err
+	}
+	return r.HEAD(base, redirectTrailingSlash, middlewares...)
+}
+
+type staticServer struct {
+	fsys   fs.FS
+	config StaticConfig
+	// notFoundHandler overrides the router's not-found handler for misses
+	notFoundHandler HandlerFunc
+	// hashes caches content-based ETags for files without a modification time, such as embed.FS
+	hashes sync.Map
+}
+
+func (s *staticServer) serve(c *Context) error {
+	name, ok := cleanStaticPath(c.Param("filepath"))
+	if !ok {
+		return s.notFound(c)
+	}
+
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return s.notFound(c)
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(c.Request.URL.Path, "/") {
+			return redirectTrailingSlash(c)
+		}
+		index := path.Join(name, s.config.Index)
+		if indexInfo, err := fs.Stat(s.fsys, index); err == nil && !indexInfo.IsDir() {
+			return s.serveFile(c, index, indexInfo)
+		}
+		if s.config.Browse {
+			return s.listDirectory(c, name)
+		}
+		return s.notFound(c)
+	}
+
+	return s.serveFile(c, name, info)
+}
+
+// notFound answers a miss with the configured handler, falling back to the
+// root router's so that Router.SetNotFoundHandler applies to static files
+func (s *staticServer) notFound(c *Context) error {
+	if s.notFoundHandler != nil {
+		return s.notFoundHandler(c)
+	}
+	if c.router != nil {
+		return c.router.notFoundHandler(c)
+	}
+	return defaultNotFound(c)
+}
+
+// redirectTrailingSlash permanently redirects to the request path with a
+// trailing slash, keeping the query string
+func redirectTrailingSlash(c *Context) error {
+	target := c.Request.URL.Path + "/"
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	http.Redirect(c.Response, c.Request, target, http.StatusMovedPermanently)
+	return nil
+}
+
+// cleanStaticPath turns a request path into a valid fs.FS name, rejecting
+// anything that could escape the root
+func cleanStaticPath(p string) (string, bool) {
+	if strings.ContainsAny(p, "\\\x00") {
+		return "", false
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func (s *staticServer) serveFile(c *Context, name string, info fs.FileInfo) error {
+	header := c.Response.Header()
+	served, servedInfo := name, info
+	variant := ""
+
+	if s.config.Precompressed {
+		if gzInfo, err := fs.Stat(s.fsys, name+".gz"); err == nil && !gzInfo.IsDir() {
+			addStaticVary(header, HeaderAcceptEncoding)
+			if acceptsGzip(c.Header(HeaderAcceptEncoding)) {
+				served, servedInfo, variant = name+".gz", gzInfo, "-gz"
+				header.Set(HeaderContentEncoding, "gzip")
+			}
+		}
+	}
+
+	f, err := s.fsys.Open(served)
+	if err != nil {
+		return s.notFound(c)
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if header.Get(HeaderContentType) == "" {
+		if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+			header.Set(HeaderContentType, ctype)
+		} else if variant != "" {
+			// Sniffing would detect the gzip container rather than the file
+			header.Set(HeaderContentType, "application/octet-stream")
+		}
+	}
+	if header.Get(HeaderETag) == "" {
+		etag, err := s.etag(served, servedInfo, content)
+		if err != nil {
+			return err
+		}
+		header.Set(HeaderETag, `"`+etag+variant+`"`)
+	}
+	if s.config.MaxAge > 0 && header.Get(HeaderCacheControl) == "" {
+		header.Set(HeaderCacheControl, "public, max-age="+strconv.Itoa(s.config.MaxAge))
+	}
+
+	http.ServeContent(c.Response, c.Request, name, servedInfo.ModTime(), content)
+	return nil
+}
+
+// etag derives a validator from size and modification time, hashing the
+// content when the file system does not record modification times
+func (s *staticServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return strconv.FormatInt(info.Size(), 16) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 16), nil
+	}
+	if etag, ok := s.hashes.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	h := fnv.New64a()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := strconv.FormatInt(info.Size(), 16) + "-" + strconv.FormatUint(h.Sum64(), 16)
+	s.hashes.Store(name, etag)
+	return etag, nil
+}
+
+func (s *staticServer) listDirectory(c *Context, name string) error {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return c.String(http.StatusForbidden, "Forbidden")
+		}
+		return err
+	}
+
+	var b strings.Builder
+	title := html.EscapeString(c.Request.URL.Path)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head><body>\n<h1>%s</h1>\n<ul>\n", title, title)
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		href := (&url.URL{Path: entryName}).String()
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(entryName))
+	}
+	b.WriteString("</ul>\n</body></html>\n")
+
+	c.Response.Header().Set(HeaderContentType, "text/html; charset=utf-8")
+	c.SetStatus(http.StatusOK)
+	_, err = io.WriteString(c.Response, b.String())
+	return err
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "x-gzip" && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func addStaticVary(header http.Header, value string) {
+	for _, v := range header.Values(HeaderVary) {
+		if strings.Contains(strings.ToLower(v), strings.ToLower(value)) {
+			return
+		}
+	}
+	header.Add(HeaderVary, value)
+}
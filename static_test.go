@@ -0,0 +1,260 @@
+package fuselage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	_ = gw.Close()
+	return buf.Bytes()
+}
+
+func newStaticFS(t *testing.T) fstest.MapFS {
+	modified := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"index.html":      {Data: []byte("<h1>home</h1>"), ModTime: modified},
+		"app.js":          {Data: []byte("console.log('app')"), ModTime: modified},
+		"app.js.gz":       {Data: gzipBytes(t, "console.log('app')"), ModTime: modified},
+		"docs/readme.txt": {Data: []byte("0123456789"), ModTime: modified},
+		"embedded.css":    {Data: []byte("body{}")},
+	}
+}
+
+func serveStatic(router *Router, method, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, http.NoBody)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouter_StaticFS(t *testing.T) {
+	router := New()
+	if err := router.StaticFS("/assets", newStaticFS(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveStatic(router, "GET", "/assets/docs/readme.txt")
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("Expected file content, got %d '%s'", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected text/plain, got '%s'", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Last-Modified") == "" || w.Header().Get("ETag") == "" {
+		t.Errorf("Expected Last-Modified and ETag headers")
+	}
+
+	w = serveStatic(router, "GET", "/assets/")
+	if w.Body.String() != "<h1>home</h1>" {
+		t.Errorf("Expected index.html for directory, got '%s'", w.Body.String())
+	}
+
+	w = serveStatic(router, "GET", "/assets/docs")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/assets/docs/" {
+		t.Errorf("Expected redirect to trailing slash, got %d '%s'", w.Code, w.Header().Get("Location"))
+	}
+
+	w = serveStatic(router, "GET", "/assets/docs/")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without listing, got %d", w.Code)
+	}
+
+	w = serveStatic(router, "HEAD", "/assets/app.js")
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("Expected HEAD to succeed without body, got %d", w.Code)
+	}
+
+	w = serveStatic(router, "GET", "/assets/missing.js")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestRouter_StaticBarePrefix(t *testing.T) {
+	router := New()
+	_ = router.StaticFS("/assets/", newStaticFS(t))
+
+	w := serveStatic(router, "GET", "/assets?v=2")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/assets/?v=2" {
+		t.Errorf("Expected redirect to trailing slash, got %d '%s'", w.Code, w.Header().Get("Location"))
+	}
+
+	w = serveStatic(router, "HEAD", "/assets")
+	if w.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected HEAD to redirect, got %d", w.Code)
+	}
+}
+
+func TestRouter_StaticNotFoundHandler(t *testing.T) {
+	router := New()
+	router.SetNotFoundHandler(func(c *Context) error {
+		return c.String(http.StatusNotFound, "custom not found")
+	})
+	_ = router.StaticFS("/assets", newStaticFS(t))
+
+	for _, target := range []string{"/assets/missing.js", "/assets/docs/"} {
+		w := serveStatic(router, "GET", target)
+		if w.Code != http.StatusNotFound || w.Body.String() != "custom not found" {
+			t.Errorf("Expected custom 404 for %s, got %d '%s'", target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_StaticNestedMounts(t *testing.T) {
+	router := New()
+	_ = router.StaticFS("/", fstest.MapFS{"root.txt": {Data: []byte("root")}})
+	_ = router.StaticFS("/assets", fstest.MapFS{"a.txt": {Data: []byte("asset")}})
+
+	// Route lookup iterates a map, so repeat to catch order dependence
+	for i := 0; i < 50; i++ {
+		if w := serveStatic(router, "GET", "/assets/a.txt"); w.Code != http.StatusOK || w.Body.String() != "asset" {
+			t.Fatalf("Expected the nested mount to serve the file, got %d '%s'", w.Code, w.Body.String())
+		}
+		if w := serveStatic(router, "GET", "/root.txt"); w.Body.String() != "root" {
+			t.Fatalf("Expected the root mount to serve the file, got %d '%s'", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_StaticRangeAndConditional(t *testing.T) {
+	router := New()
+	_ = router.StaticFS("/assets", newStaticFS(t))
+
+	w := serveStatic(router, "GET", "/assets/docs/readme.txt", "Range", "bytes=2-5")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Errorf("Expected partial content '2345', got %d '%s'", w.Code, w.Body.String())
+	}
+
+	w = serveStatic(router, "GET", "/assets/docs/readme.txt")
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+
+	w = serveStatic(router, "GET", "/assets/docs/readme.txt", "If-None-Match", etag)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	w = serveStatic(router, "GET", "/assets/docs/readme.txt", "If-Modified-Since", lastModified)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+
+	// Files without modification times, such as embed.FS, get content hashes
+	w = serveStatic(router, "GET", "/assets/embedded.css")
+	if w.Header().Get("ETag") == "" || w.Header().Get("Last-Modified") != "" {
+		t.Errorf("Expected content ETag without Last-Modified")
+	}
+}
+
+func TestRouter_StaticPrecompressed(t *testing.T) {
+	router := New()
+	_ = router.StaticFS("/assets", newStaticFS(t))
+
+	w := serveStatic(router, "GET", "/assets/app.js", "Accept-Encoding", "br, gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip variant, got '%s'", w.Header().Get("Content-Encoding"))
+	}
+	if !strings.Contains(w.Header().Get("Content-Type"), "javascript") {
+		t.Errorf("Expected JavaScript content type, got '%s'", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding")
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded bytes.Buffer
+	_, _ = decoded.ReadFrom(gr)
+	if decoded.String() != "console.log('app')" {
+		t.Errorf("Unexpected decoded content '%s'", decoded.String())
+	}
+	gzipETag := w.Header().Get("ETag")
+
+	w = serveStatic(router, "GET", "/assets/app.js")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "console.log('app')" {
+		t.Errorf("Expected identity content without Accept-Encoding")
+	}
+	if w.Header().Get("ETag") == gzipETag {
+		t.Errorf("Expected distinct ETags per encoding")
+	}
+}
+
+func TestRouter_StaticTraversal(t *testing.T) {
+	dir := t.TempDir()
+	public := filepath.Join(dir, "public")
+	if err := os.Mkdir(public, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o600)
+	_ = os.WriteFile(filepath.Join(public, "ok.txt"), []byte("ok"), 0o600)
+
+	router := New()
+	_ = router.Static("/files", public)
+
+	if w := serveStatic(router, "GET", "/files/ok.txt"); w.Body.String() != "ok" {
+		t.Fatalf("Expected file from disk, got '%s'", w.Body.String())
+	}
+
+	for _, target := range []string{"/files/../secret.txt", "/files/%2e%2e/secret.txt", "/files/..%5csecret.txt"} {
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		req.URL.Path = strings.ReplaceAll(strings.ReplaceAll(target, "%2e", "."), "%5c", "\\")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("Traversal via %s exposed a file outside the root", target)
+		}
+	}
+}
+
+func TestRouter_StaticBrowse(t *testing.T) {
+	router := New()
+	_ = router.StaticWithConfig("/browse", newStaticFS(t), StaticConfig{Browse: true, MaxAge: 3600})
+
+	w := serveStatic(router, "GET", "/browse/docs/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="readme.txt">readme.txt</a>`) {
+		t.Errorf("Expected directory listing, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	w = serveStatic(router, "GET", "/browse/app.js")
+	if w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("Expected Cache-Control header, got '%s'", w.Header().Get("Cache-Control"))
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected precompressed variants to be off unless configured")
+	}
+}
+
+func TestRouter_Wildcard(t *testing.T) {
+	router := New()
+	_ = router.GET("/files/*path", func(c *Context) error {
+		return c.String(http.StatusOK, "wildcard:"+c.Param("path"))
+	})
+	_ = router.GET("/files/:name", func(c *Context) error {
+		return c.String(http.StatusOK, "param:"+c.Param("name"))
+	})
+
+	if w := serveStatic(router, "GET", "/files/a/b/c"); w.Body.String() != "wildcard:a/b/c" {
+		t.Errorf("Expected wildcard match, got '%s'", w.Body.String())
+	}
+	if w := serveStatic(router, "GET", "/files/a"); w.Body.String() != "param:a" {
+		t.Errorf("Expected parameter route to take precedence, got '%s'", w.Body.String())
+	}
+}
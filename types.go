@@ -5,3 +5,24 @@ type ParamKey string
 
 // RequestIDKey is the context key for request ID
 const RequestIDKey ParamKey = "request_id"
+
+// SessionKey is the context key for the session
+const SessionKey ParamKey = "session"
+
+// CSRFTokenKey is the context key for the CSRF token
+const CSRFTokenKey ParamKey = "csrf_token"
+
+// CSPNonceKey is the context key for the Content-Security-Policy nonce
+const CSPNonceKey ParamKey = "csp_nonce"
+
+// PrincipalKey is the context key for the authenticated principal
+const PrincipalKey ParamKey = "principal"
+
+// JWTClaimsKey is the context key for verified JWT claims
+const JWTClaimsKey ParamKey = "jwt_claims"
+
+// APIKeyKey is the context key for the authenticated API key
+const APIKeyKey ParamKey = "api_key"
+
+// IntrospectionKey is the context key for the token introspection result
+const IntrospectionKey ParamKey = "introspection"
@@ -0,0 +1,514 @@
+package fuselage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec // SHA-1 is mandated by RFC 6455 for the handshake
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types as defined by RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket close codes as defined by RFC 6455
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	headerSecWebSocketKey      = "Sec-WebSocket-Key"
+	headerSecWebSocketAccept   = "Sec-WebSocket-Accept"
+	headerSecWebSocketVersion  = "Sec-WebSocket-Version"
+	headerSecWebSocketProtocol = "Sec-WebSocket-Protocol"
+
+	webSocketGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxControlFrameSize = 125
+	continuationFrame   = 0
+)
+
+// ErrBadHandshake is returned when a request is not a valid WebSocket upgrade
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// CloseError is returned by ReadMessage when the connection is closed
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// WebSocketHandler handles an upgraded WebSocket connection
+type WebSocketHandler func(*Context, *WebSocketConn) error
+
+// WebSocketConfig configures the WebSocket upgrade
+type WebSocketConfig struct {
+	// ReadLimit is the maximum size in bytes of an incoming message
+	ReadLimit int64
+	// FragmentSize splits outgoing messages into frames of at most this size (0 disables fragmentation)
+	FragmentSize int
+	// Subprotocols lists the supported subprotocols in order of preference
+	Subprotocols []string
+	// CheckOrigin decides whether the Origin header is acceptable (default: same host)
+	CheckOrigin func(*Context) bool
+}
+
+var DefaultWebSocketConfig = WebSocketConfig{
+	ReadLimit:   1 << 20,
+	CheckOrigin: sameOrigin,
+}
+
+// WebSocketConn is an upgraded WebSocket connection
+type WebSocketConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	readLimit    int64
+	fragmentSize int
+	subprotocol  string
+	pongHandler  func([]byte)
+
+	writeMutex sync.Mutex
+	closeSent  bool
+}
+
+// WebSocket registers a GET route that upgrades the connection and runs handler.
+// Route and router middleware run before the handshake.
+func (r *Router) WebSocket(path string, handler WebSocketHandler, middlewares ...MiddlewareFunc) error {
+	return r.WebSocketWithConfig(path, handler, DefaultWebSocketConfig, middlewares...)
+}
+
+// WebSocketWithConfig is like WebSocket but upgrades with the given config
+func (r *Router) WebSocketWithConfig(path string, handler WebSocketHandler, config WebSocketConfig, middlewares ...MiddlewareFunc) error {
+	return r.GET(path, func(c *Context) error {
+		ws, err := c.Upgrade(config)
+		if err != nil {
+			// The handshake failure response has already been sent
+			return nil
+		}
+		defer ws.conn.Close()
+
+		if err := handler(c, ws); err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) && isNormalClose(closeErr.Code) {
+				return nil
+			}
+			_ = ws.Close(CloseInternalServerErr, "")
+			return err
+		}
+		return ws.Close(CloseNormalClosure, "")
+	}, middlewares...)
+}
+
+// Upgrade performs the RFC 6455 handshake and takes over the connection.
+// On failure an error response has already been written.
+func (c *Context) Upgrade(config WebSocketConfig) (*WebSocketConn, error) {
+	if config.ReadLimit <= 0 {
+		config.ReadLimit = DefaultWebSocketConfig.ReadLimit
+	}
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = DefaultWebSocketConfig.CheckOrigin
+	}
+
+	req := c.Request
+	if req.Method != GET ||
+		!headerContainsToken(req.Header, HeaderConnection, "upgrade") ||
+		!headerContainsToken(req.Header, HeaderUpgrade, "websocket") {
+		_ = c.String(http.StatusBadRequest, "Bad Request")
+		return nil, ErrBadHandshake
+	}
+	if req.Header.Get(headerSecWebSocketVersion) != "13" {
+		c.SetHeader(headerSecWebSocketVersion, "13")
+		_ = c.String(http.StatusUpgradeRequired, "Unsupported WebSocket version")
+		return nil, ErrBadHandshake
+	}
+	key := req.Header.Get(headerSecWebSocketKey)
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		_ = c.String(http.StatusBadRequest, "Bad Request")
+		return nil, ErrBadHandshake
+	}
+	if !config.CheckOrigin(c) {
+		_ = c.String(http.StatusForbidden, "Forbidden")
+		return nil, ErrBadHandshake
+	}
+
+	subprotocol := selectSubprotocol(req, config.Subprotocols)
+
+	conn, rw, err := http.NewResponseController(c.Response).Hijack()
+	if err != nil {
+		_ = c.String(http.StatusInternalServerError, "WebSocket upgrade unsupported")
+		return nil, err
+	}
+	// Clear deadlines inherited from the server configuration
+	_ = conn.SetDeadline(time.Time{})
+
+	header := c.Response.Header().Clone()
+	header.Del(HeaderContentType)
+	header.Del(HeaderContentLength)
+	header.Set(HeaderUpgrade, "websocket")
+	header.Set(HeaderConnection, "Upgrade")
+	header.Set(headerSecWebSocketAccept, computeAcceptKey(key))
+	if subprotocol != "" {
+		header.Set(headerSecWebSocketProtocol, subprotocol)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	_ = header.Write(&buf)
+	buf.WriteString("\r\n")
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	c.Response.status = http.StatusSwitchingProtocols
+
+	return &WebSocketConn{
+		conn:         conn,
+		reader:       rw.Reader,
+		readLimit:    config.ReadLimit,
+		fragmentSize: config.FragmentSize,
+		subprotocol:  subprotocol,
+	}, nil
+}
+
+// Subprotocol returns the negotiated subprotocol
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr returns the peer network address
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the deadline for future reads
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future writes
+func (ws *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit sets the maximum size in bytes of an incoming message
+func (ws *WebSocketConn) SetReadLimit(limit int64) {
+	ws.readLimit = limit
+}
+
+// SetPongHandler sets a function called with the payload of each pong
+func (ws *WebSocketConn) SetPongHandler(handler func([]byte)) {
+	ws.pongHandler = handler
+}
+
+// ReadMessage reads the next complete data message, reassembling fragments.
+// Pings are answered automatically; a close frame is returned as *CloseError.
+func (ws *WebSocketConn) ReadMessage() (int, []byte, error) {
+	var message []byte
+	messageType := 0
+
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := ws.writeFrame(true, PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				ws.pongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			if len(payload) == 1 {
+				return 0, nil, ws.fail(CloseProtocolError, "invalid close frame")
+			}
+			closeErr := parseClosePayload(payload)
+			if len(payload) >= 2 && !validCloseCode(closeErr.Code) {
+				return 0, nil, ws.fail(CloseProtocolError, "invalid close code")
+			}
+			code := closeErr.Code
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+			_ = ws.Close(code, "")
+			return 0, nil, closeErr
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if int64(len(message)+len(payload)) > ws.readLimit {
+			return 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, ws.fail(CloseInvalidFramePayloadData, "invalid UTF-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage writes a text or binary message, fragmenting it if configured
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return ws.writeFrame(true, messageType, data)
+	}
+
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	if ws.fragmentSize <= 0 || len(data) <= ws.fragmentSize {
+		return ws.writeFrameLocked(true, messageType, data)
+	}
+
+	opcode := messageType
+	for len(data) > 0 {
+		n := min(ws.fragmentSize, len(data))
+		if err := ws.writeFrameLocked(n == len(data), opcode, data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+		opcode = continuationFrame
+	}
+	return nil
+}
+
+// Ping sends a ping control frame
+func (ws *WebSocketConn) Ping(data []byte) error {
+	return ws.writeFrame(true, PingMessage, data)
+}
+
+// Close sends a close frame with the given code and closes the connection
+func (ws *WebSocketConn) Close(code int, reason string) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	if ws.closeSent {
+		return nil
+	}
+	ws.closeSent = true
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlFrameSize {
+		payload = payload[:maxControlFrameSize]
+	}
+
+	err := ws.writeFrameLocked(true, CloseMessage, payload)
+	if closeErr := ws.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (ws *WebSocketConn) fail(code int, text string) error {
+	_ = ws.Close(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+func (ws *WebSocketConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.reader, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return false, 0, nil, ws.fail(CloseProtocolError, "invalid frame length")
+		}
+	}
+
+	if opcode >= CloseMessage && (length > maxControlFrameSize || !fin) {
+		return false, 0, nil, ws.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length > ws.readLimit {
+		return false, 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (ws *WebSocketConn) writeFrame(fin bool, opcode int, payload []byte) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+	return ws.writeFrameLocked(fin, opcode, payload)
+}
+
+func (ws *WebSocketConn) writeFrameLocked(fin bool, opcode int, payload []byte) error {
+	if ws.closeSent && opcode != CloseMessage {
+		return net.ErrClosed
+	}
+	if opcode >= CloseMessage && len(payload) > maxControlFrameSize {
+		return errors.New("websocket: control frame payload too large")
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = byte(opcode)
+	if fin {
+		header[0] |= 0x80
+	}
+
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if _, err := ws.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+func parseClosePayload(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CloseNoStatusReceived}
+	}
+	return &CloseError{
+		Code: int(binary.BigEndian.Uint16(payload)),
+		Text: string(payload[2:]),
+	}
+}
+
+// validCloseCode reports whether a peer may send code in a close frame.
+// 1005, 1006 and 1015 are reserved for local use and must not appear on the wire.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func isNormalClose(code int) bool {
+	return code == CloseNormalClosure || code == CloseGoingAway || code == CloseNoStatusReceived
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New() //nolint:gosec // required by RFC 6455
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func selectSubprotocol(req *http.Request, supported []string) string {
+	for _, offered := range strings.Split(req.Header.Get(headerSecWebSocketProtocol), ",") {
+		offered = strings.TrimSpace(offered)
+		for _, s := range supported {
+			if offered == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(c *Context) bool {
+	origin := c.Header(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, c.Request.Host)
+}
@@ -0,0 +1,250 @@
+package fuselage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func dialWebSocket(t *testing.T, server *httptest.Server, path string, header http.Header) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", server.URL+path, http.NoBody)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("Failed to write handshake: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	return conn, br, resp
+}
+
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode int, payload []byte) {
+	t.Helper()
+
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (fin bool, opcode int, payload []byte) {
+	t.Helper()
+
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("Failed to read payload: %v", err)
+	}
+	return head[0]&0x80 != 0, int(head[0] & 0x0f), payload
+}
+
+func newEchoServer() *httptest.Server {
+	router := New()
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			c.SetHeader(HeaderXRequestID, "ws-test")
+			return next(c)
+		}
+	})
+	_ = router.WebSocket("/ws", func(c *Context, ws *WebSocketConn) error {
+		ws.SetReadLimit(64)
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				return err
+			}
+			if err := ws.WriteMessage(messageType, data); err != nil {
+				return err
+			}
+		}
+	})
+	return httptest.NewServer(router)
+}
+
+func TestRouter_WebSocketHandshake(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+
+	conn, _, resp := dialWebSocket(t, server, "/ws", nil)
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key '%s'", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	if resp.Header.Get(HeaderXRequestID) != "ws-test" {
+		t.Errorf("Expected middleware headers on the handshake response")
+	}
+}
+
+func TestRouter_WebSocketEcho(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+
+	conn, br, _ := dialWebSocket(t, server, "/ws", nil)
+	defer conn.Close()
+
+	// Fragmented text message with an interleaved ping
+	writeClientFrame(t, conn, false, TextMessage, []byte("hel"))
+	writeClientFrame(t, conn, true, PingMessage, []byte("p"))
+	writeClientFrame(t, conn, true, continuationFrame, []byte("lo"))
+
+	_, opcode, payload := readServerFrame(t, br)
+	if opcode != PongMessage || string(payload) != "p" {
+		t.Errorf("Expected pong 'p', got opcode %d payload '%s'", opcode, payload)
+	}
+
+	fin, opcode, payload := readServerFrame(t, br)
+	if !fin || opcode != TextMessage || string(payload) != "hello" {
+		t.Errorf("Expected echoed 'hello', got opcode %d payload '%s'", opcode, payload)
+	}
+
+	writeClientFrame(t, conn, true, CloseMessage, []byte{0x03, 0xe8})
+	_, opcode, payload = readServerFrame(t, br)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseNormalClosure {
+		t.Errorf("Expected normal close reply, got opcode %d payload %v", opcode, payload)
+	}
+}
+
+func TestRouter_WebSocketReadLimit(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+
+	conn, br, _ := dialWebSocket(t, server, "/ws", nil)
+	defer conn.Close()
+
+	writeClientFrame(t, conn, true, BinaryMessage, make([]byte, 100))
+
+	_, opcode, payload := readServerFrame(t, br)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+		t.Errorf("Expected close 1009, got opcode %d payload %v", opcode, payload)
+	}
+}
+
+func TestRouter_WebSocketWithConfig(t *testing.T) {
+	router := New()
+	_ = router.WebSocketWithConfig("/ws", func(c *Context, ws *WebSocketConn) error {
+		_, _, err := ws.ReadMessage()
+		return err
+	}, WebSocketConfig{
+		ReadLimit:    16,
+		Subprotocols: []string{"chat"},
+		CheckOrigin:  func(*Context) bool { return true },
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, br, resp := dialWebSocket(t, server, "/ws", http.Header{
+		"Origin":                 {"https://other.example"},
+		"Sec-WebSocket-Protocol": {"chat"},
+	})
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Protocol") != "chat" {
+		t.Errorf("Expected subprotocol chat, got '%s'", resp.Header.Get("Sec-WebSocket-Protocol"))
+	}
+
+	writeClientFrame(t, conn, true, TextMessage, make([]byte, 32))
+	_, opcode, payload := readServerFrame(t, br)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+		t.Errorf("Expected close 1009 from configured ReadLimit, got opcode %d payload %v", opcode, payload)
+	}
+}
+
+func TestRouter_WebSocketInvalidCloseCode(t *testing.T) {
+	for _, code := range []uint16{999, 1005, 1006, 1015, 2000} {
+		server := newEchoServer()
+
+		conn, br, _ := dialWebSocket(t, server, "/ws", nil)
+		writeClientFrame(t, conn, true, CloseMessage, binary.BigEndian.AppendUint16(nil, code))
+
+		_, opcode, payload := readServerFrame(t, br)
+		if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseProtocolError {
+			t.Errorf("Code %d: expected close 1002, got opcode %d payload %v", code, opcode, payload)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestRouter_WebSocketOriginRejected(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+
+	conn, _, resp := dialWebSocket(t, server, "/ws", http.Header{"Origin": {"https://evil.example"}})
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestContext_UpgradeRejectsPlainRequest(t *testing.T) {
+	c := &Context{
+		Request:  httptest.NewRequest("GET", "/ws", http.NoBody),
+		Response: NewResponseWriter(httptest.NewRecorder()),
+	}
+
+	if _, err := c.Upgrade(DefaultWebSocketConfig); !errors.Is(err, ErrBadHandshake) {
+		t.Errorf("Expected ErrBadHandshake, got %v", err)
+	}
+	if c.Status() != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", c.Status())
+	}
+}